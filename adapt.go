@@ -0,0 +1,143 @@
+package gozstd
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// adaptSlack is how much faster than the input and output the compression
+// has to be before the level goes up.
+const adaptSlack = 0.8
+
+// adapter is the BlockEncoder of Options.Adapt. It keeps the compression just
+// a bit faster than the slowest of reading the input and writing the output:
+// when the encoder has time to spare the level goes up, when the pipe waits
+// for it the level goes down. There is an encoder per level, made the first
+// time the level is used.
+type adapter struct {
+	opts     Options
+	min, max int
+	level    atomic.Int64
+
+	mu       sync.Mutex
+	encoders map[int]BlockEncoder
+	frames   int
+	read     time.Duration
+	compress time.Duration
+	write    time.Duration
+}
+
+func newAdapter(o Options) *adapter {
+	a := &adapter{opts: o, min: o.AdaptMin, max: o.AdaptMax, encoders: make(map[int]BlockEncoder)}
+	if a.min == 0 {
+		a.min = 1
+	}
+	if a.max == 0 {
+		a.max = 19
+	}
+	a.level.Store(int64(min(max(o.Level, a.min), a.max)))
+	return a
+}
+
+// Level returns the level of the next frame.
+func (a *adapter) Level() int {
+	return int(a.level.Load())
+}
+
+func (a *adapter) Encode(src, dst []byte) ([]byte, error) {
+	return a.encode(nil, src, dst, false)
+}
+
+func (a *adapter) EncodePrimed(history, src, dst []byte) ([]byte, error) {
+	return a.encode(history, src, dst, true)
+}
+
+func (a *adapter) encode(history, src, dst []byte, primed bool) ([]byte, error) {
+	encoder, err := a.encoder(a.Level())
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	if primed {
+		primer, ok := encoder.(primingEncoder)
+		if !ok {
+			return nil, errNoPrime
+		}
+		dst, err = primer.EncodePrimed(history, src, dst)
+	} else {
+		dst, err = encoder.Encode(src, dst)
+	}
+	a.mu.Lock()
+	a.compress += time.Since(start)
+	a.mu.Unlock()
+	return dst, err
+}
+
+func (a *adapter) encoder(level int) (BlockEncoder, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if encoder, ok := a.encoders[level]; ok {
+		return encoder, nil
+	}
+	o := a.opts
+	o.Level = level
+	encoder, err := o.backend().NewBlockEncoder(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder for level %d: %w", level, err)
+	}
+	a.encoders[level] = encoder
+	return encoder, nil
+}
+
+func (a *adapter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var err error
+	for _, encoder := range a.encoders {
+		if cerr := encoder.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// The methods below do nothing on a nil adapter, when Adapt is off.
+
+// addRead records the time spent reading the input of a frame.
+func (a *adapter) addRead(d time.Duration) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.read += d
+	a.mu.Unlock()
+}
+
+// addWrite records the time a frame took to write and, once every Threads
+// frames, moves the level by one if needed.
+func (a *adapter) addWrite(d time.Duration) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.write += d
+	a.frames++
+	if a.frames < a.opts.Threads {
+		return
+	}
+
+	// The workers compress Threads frames at once.
+	compress := a.compress / time.Duration(a.opts.Threads)
+	slowest := max(a.read, a.write)
+	level := a.Level()
+	switch {
+	case compress > slowest && level > a.min:
+		a.level.Store(int64(level - 1))
+	case float64(compress) < adaptSlack*float64(slowest) && level < a.max:
+		a.level.Store(int64(level + 1))
+	}
+	a.frames, a.read, a.compress, a.write = 0, 0, 0, 0
+}
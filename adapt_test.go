@@ -0,0 +1,42 @@
+package gozstd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAdaptRoundTrip(t *testing.T) {
+	data := testData(oneMB)
+	archive := compressBytes(t, Options{Level: 3, Threads: 4, Mode: ModeBlock, Adapt: true, AdaptMin: 1, AdaptMax: 6, FrameSize: 64 << 10}, data)
+	for _, threads := range []int{1, 4} {
+		if got := decompressBytes(t, Options{Threads: threads}, archive); !bytes.Equal(got, data) {
+			t.Fatalf("%d threads: got %d bytes back", threads, len(got))
+		}
+	}
+}
+
+func TestAdaptLevel(t *testing.T) {
+	a := newAdapter(Options{Level: 3, Threads: 2, AdaptMin: 2, AdaptMax: 4})
+	// A slow output gives the encoder time for a higher level, up to AdaptMax.
+	for i := 0; i < 4; i++ {
+		a.addWrite(time.Second)
+	}
+	if a.Level() != 4 {
+		t.Fatalf("level %d after a slow output, want 4", a.Level())
+	}
+	// An encoder slower than the pipe goes down, not below AdaptMin.
+	for i := 0; i < 4; i++ {
+		a.mu.Lock()
+		a.compress = 10 * time.Second
+		a.mu.Unlock()
+		a.addWrite(time.Millisecond)
+		a.addWrite(time.Millisecond)
+	}
+	if a.Level() != 2 {
+		t.Fatalf("level %d after a slow encoder, want 2", a.Level())
+	}
+	if b := newAdapter(Options{Level: 9, Threads: 1}); b.Level() != 9 || b.min != 1 || b.max != 19 {
+		t.Errorf("zero range: level %d in %d-%d, want 9 in 1-19", b.Level(), b.min, b.max)
+	}
+}
@@ -0,0 +1,64 @@
+package gozstd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+// autoBlockLevel is the lowest level where ModeAuto uses block mode. Below it
+// the stream encoder is about as fast and compresses better.
+const autoBlockLevel = 10
+
+// ChooseMode returns the mode used to compress an input of size bytes, or of
+// unknown size when negative, and the reason for it. Unless Options.Mode is
+// ModeAuto it is always Options.Mode.
+func (c *Compressor) ChooseMode(size int64) (Mode, string) {
+	o := c.opts
+	cpus := runtime.GOMAXPROCS(0)
+	threads := min(o.Threads, cpus)
+	switch {
+	case o.Mode != ModeAuto:
+		return o.Mode, "set in the options"
+	case o.Seekable:
+		return ModeBlock, "the seekable format needs block mode"
+	case o.Adapt:
+		return ModeBlock, "the level adapts between the frames of block mode"
+	case o.Prime != 0:
+		return ModeBlock, "frames are primed in block mode"
+	case o.SelfDict:
+		return ModeBlock, "the dictionary is trained for the frames of block mode"
+	case o.Threads < 2:
+		return ModeStream, "there is only one thread"
+	case cpus < 2:
+		return ModeStream, "there is only one CPU"
+	case isThreadedStreamer(o.backend()):
+		return ModeStream, fmt.Sprintf("%s compresses the stream on %d threads itself", o.backend().Name(), threads)
+	case o.Level < autoBlockLevel:
+		return ModeStream, fmt.Sprintf("level %d is fast enough for the stream encoder, block mode only pays off from level %d", o.Level, autoBlockLevel)
+	case size >= 0 && size < int64(threads)*int64(o.chunkSize()):
+		return ModeStream, fmt.Sprintf("the input of %d bytes does not make a chunk for each of the %d threads", size, threads)
+	case size < 0:
+		return ModeBlock, fmt.Sprintf("level %d on %d threads, the input size is unknown", o.Level, threads)
+	}
+	return ModeBlock, fmt.Sprintf("level %d on %d threads with %d bytes of input", o.Level, threads, size)
+}
+
+func (c *Compressor) mode(size int64) Mode {
+	mode, _ := c.ChooseMode(size)
+	return mode
+}
+
+// readerSize returns the size of r if it is a regular file, or -1.
+func readerSize(r io.Reader) int64 {
+	f, ok := r.(*os.File)
+	if !ok {
+		return -1
+	}
+	finfo, err := f.Stat()
+	if err != nil || !finfo.Mode().IsRegular() {
+		return -1
+	}
+	return finfo.Size()
+}
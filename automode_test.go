@@ -0,0 +1,58 @@
+package gozstd
+
+import (
+	"runtime"
+	"testing"
+)
+
+// threadedBackend compresses streams on several threads, like libzstd.
+type threadedBackend struct {
+	pureGoBackend
+}
+
+func (threadedBackend) threadedStream() {}
+
+func TestChooseMode(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	pureGo := pureGoBackend{}
+	tests := []struct {
+		name string
+		opts Options
+		size int64
+		want Mode
+	}{
+		{"set stream", Options{Level: 19, Threads: 4, Mode: ModeStream}, -1, ModeStream},
+		{"set block", Options{Level: 1, Threads: 1, Mode: ModeBlock}, 10, ModeBlock},
+		{"seekable", Options{Level: 1, Threads: 1, Mode: ModeAuto, Seekable: true}, 10, ModeBlock},
+		{"adapt", Options{Level: 1, Threads: 1, Mode: ModeAuto, Adapt: true}, 10, ModeBlock},
+		{"prime", Options{Level: 1, Threads: 1, Mode: ModeAuto, Prime: 1024}, 10, ModeBlock},
+		{"self dict", Options{Level: 1, Threads: 1, Mode: ModeAuto, SelfDict: true}, 10, ModeBlock},
+		{"one thread", Options{Level: 19, Threads: 1, Mode: ModeAuto}, 1 << 30, ModeStream},
+		{"low level", Options{Level: 9, Threads: 4, Mode: ModeAuto}, 1 << 30, ModeStream},
+		{"small input", Options{Level: 19, Threads: 4, Mode: ModeAuto}, 4*oneMB - 1, ModeStream},
+		{"large input", Options{Level: 19, Threads: 4, Mode: ModeAuto}, 4 * oneMB, ModeBlock},
+		{"unknown size", Options{Level: 10, Threads: 4, Mode: ModeAuto}, -1, ModeBlock},
+		{"large chunks", Options{Level: 19, Threads: 4, Mode: ModeAuto, ChunkSize: 16 * oneMB}, 32 * oneMB, ModeStream},
+	}
+	for _, tt := range tests {
+		tt.opts.Backend = pureGo
+		c, err := NewCompressor(tt.opts)
+		if err != nil {
+			t.Fatalf("%s: NewCompressor: %v", tt.name, err)
+		}
+		if got, why := c.ChooseMode(tt.size); got != tt.want || why == "" {
+			t.Errorf("%s: got %s mode (%s), want %s", tt.name, got, why, tt.want)
+		}
+	}
+
+	c, _ := NewCompressor(Options{Level: 19, Threads: 4, Mode: ModeAuto, Backend: threadedBackend{}})
+	if got, why := c.ChooseMode(1 << 30); got != ModeStream {
+		t.Errorf("threaded stream encoder: got %s mode (%s), want stream", got, why)
+	}
+
+	runtime.GOMAXPROCS(1)
+	c, _ = NewCompressor(Options{Level: 19, Threads: 4, Mode: ModeAuto, Backend: pureGo})
+	if got, why := c.ChooseMode(1 << 30); got != ModeStream {
+		t.Errorf("one CPU: got %s mode (%s), want stream", got, why)
+	}
+}
@@ -0,0 +1,112 @@
+package gozstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Backend is the zstd encoder implementation used by a Compressor. The pure
+// Go encoder from klauspost/compress is the default. Building with the
+// cgo_zstd tag switches the default to libzstd.
+type Backend interface {
+	// Name identifies the backend in messages.
+	Name() string
+	// MaxLevel is the highest compression level the backend supports.
+	MaxLevel() int
+	// NewStreamEncoder returns an encoder writing a single frame to w.
+	NewStreamEncoder(w io.Writer, o Options) (io.WriteCloser, error)
+	// NewBlockEncoder returns an encoder making one independent frame per
+	// call, it must be safe for concurrent use.
+	NewBlockEncoder(o Options) (BlockEncoder, error)
+}
+
+// BlockEncoder compresses a whole buffer into one frame.
+type BlockEncoder interface {
+	// Encode appends the frame for src to dst.
+	Encode(src, dst []byte) ([]byte, error)
+	Close() error
+}
+
+// threadedStreamer is implemented by backends whose stream encoder uses
+// Options.Threads itself, ModeAuto then prefers stream mode.
+type threadedStreamer interface {
+	threadedStream()
+}
+
+func isThreadedStreamer(b Backend) bool {
+	_, ok := b.(threadedStreamer)
+	return ok
+}
+
+// optionChecker is implemented by backends which cannot do every option,
+// NewCompressor then rejects those before anything is compressed.
+type optionChecker interface {
+	checkOptions(o Options) error
+}
+
+var defaultBackend Backend = pureGoBackend{}
+
+// DefaultBackend returns the backend used when Options.Backend is nil.
+func DefaultBackend() Backend {
+	return defaultBackend
+}
+
+// pureGoBackend is the klauspost/compress encoder. It only has four speed
+// tiers, EncoderLevelFromZstd maps the zstd levels onto them.
+type pureGoBackend struct{}
+
+func (pureGoBackend) Name() string {
+	return "pure Go (klauspost/compress)"
+}
+
+func (pureGoBackend) MaxLevel() int {
+	return 19
+}
+
+// checkOptions rejects a dictionary the encoder cannot load, it only takes
+// the zstd dictionary format.
+func (pureGoBackend) checkOptions(o Options) error {
+	return checkDict(o.Dict)
+}
+
+func (pureGoBackend) NewStreamEncoder(w io.Writer, o Options) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, o.encoderOptions()...)
+}
+
+func (pureGoBackend) NewBlockEncoder(o Options) (BlockEncoder, error) {
+	options := append(o.encoderOptions(), zstd.WithEncoderConcurrency(o.Threads))
+	encoder, err := zstd.NewWriter(nil, options...)
+	if err != nil {
+		return nil, err
+	}
+	return pureGoBlockEncoder{encoder, o}, nil
+}
+
+type pureGoBlockEncoder struct {
+	encoder *zstd.Encoder
+	opts    Options
+}
+
+func (e pureGoBlockEncoder) Encode(src, dst []byte) ([]byte, error) {
+	return e.encoder.EncodeAll(src, dst), nil
+}
+
+// EncodePrimed needs an encoder of its own since the dictionary is set when
+// the encoder is made.
+func (e pureGoBlockEncoder) EncodePrimed(history, src, dst []byte) ([]byte, error) {
+	if len(history) == 0 {
+		return e.Encode(src, dst)
+	}
+	options := append(e.opts.encoderOptions(), zstd.WithEncoderDictRaw(primeDictID, history), zstd.WithEncoderConcurrency(1))
+	encoder, err := zstd.NewWriter(nil, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll(src, dst), nil
+}
+
+func (e pureGoBlockEncoder) Close() error {
+	return e.encoder.Close()
+}
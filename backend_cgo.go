@@ -0,0 +1,108 @@
+//go:build cgo_zstd
+
+package gozstd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	ddzstd "github.com/DataDog/zstd"
+)
+
+func init() {
+	defaultBackend = cgoBackend{}
+}
+
+// cgoBackend is libzstd through github.com/DataDog/zstd. It has the real
+// compression levels, levels 20-22 with Ultra, and native multithreading in
+// stream mode. libzstd does not write content checksums through this API.
+type cgoBackend struct{}
+
+func (cgoBackend) Name() string {
+	return "libzstd (cgo)"
+}
+
+func (cgoBackend) MaxLevel() int {
+	return 22
+}
+
+func (cgoBackend) threadedStream() {}
+
+// errNoWindow is returned for Options.WindowSize, the DataDog/zstd API has no
+// way to set the window log.
+var errNoWindow = withKind(fmt.Errorf("%w: window size with the libzstd backend", errors.ErrUnsupported), ErrUsage)
+
+func (cgoBackend) checkOptions(o Options) error {
+	if o.WindowSize != 0 {
+		return errNoWindow
+	}
+	if o.Prime != 0 {
+		return errNoPrime
+	}
+	return nil
+}
+
+func (cgoBackend) NewStreamEncoder(w io.Writer, o Options) (io.WriteCloser, error) {
+	if o.WindowSize != 0 {
+		return nil, errNoWindow
+	}
+	writer := ddzstd.NewWriterLevelDict(w, o.Level, o.Dict)
+	if o.Threads > 1 {
+		if err := writer.SetNbWorkers(o.Threads); err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+func (cgoBackend) NewBlockEncoder(o Options) (BlockEncoder, error) {
+	if o.WindowSize != 0 {
+		return nil, errNoWindow
+	}
+	e := &cgoBlockEncoder{level: o.Level}
+	if o.Dict != nil {
+		bulk, err := ddzstd.NewBulkProcessor(o.Dict, o.Level)
+		if err != nil {
+			return nil, err
+		}
+		e.bulk = bulk
+	}
+	e.ctxs.New = func() any {
+		return ddzstd.NewCtx()
+	}
+	return e, nil
+}
+
+// cgoBlockEncoder keeps a pool of contexts since a libzstd context can only
+// be used by one goroutine at a time.
+type cgoBlockEncoder struct {
+	level int
+	bulk  *ddzstd.BulkProcessor
+	ctxs  sync.Pool
+}
+
+func (e *cgoBlockEncoder) Encode(src, dst []byte) ([]byte, error) {
+	var out []byte
+	var err error
+	if e.bulk != nil {
+		out, err = e.bulk.Compress(nil, src)
+	} else {
+		ctx := e.ctxs.Get().(ddzstd.Ctx)
+		out, err = ctx.CompressLevel(nil, src, e.level)
+		e.ctxs.Put(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(dst) == 0 {
+		return out, nil
+	}
+	return append(dst, out...), nil
+}
+
+func (e *cgoBlockEncoder) Close() error {
+	return nil
+}
@@ -0,0 +1,202 @@
+package gozstd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Compressor compresses data with the configured Options.
+type Compressor struct {
+	opts Options
+}
+
+// NewCompressor returns a Compressor after validating opts.
+func NewCompressor(opts Options) (*Compressor, error) {
+	if err := opts.validate(); err != nil {
+		return nil, withKind(err, ErrUsage)
+	}
+	return &Compressor{opts: opts}, nil
+}
+
+// Options returns the options of the Compressor.
+func (c *Compressor) Options() Options {
+	return c.opts
+}
+
+// Compress compresses input to output. In block mode input is read in 1 MB
+// chunks, or WindowSize if larger, which are compressed in parallel into independent frames, so it
+// works with pipes like stdin and stdout.
+func (c *Compressor) Compress(input io.Reader, output io.Writer) error {
+	if c.mode(readerSize(input)) == ModeBlock {
+		return c.compressBlockStream(input, output)
+	}
+	return c.CompressStream(input, output)
+}
+
+// CompressStream compresses input to output using a single streaming encoder.
+func (c *Compressor) CompressStream(input io.Reader, output io.Writer) error {
+	input, output = ioReader{input}, ioWriter{output}
+	encoder, err := c.opts.backend().NewStreamEncoder(c.opts.Progress.writer(output), c.opts)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+
+	_, err = io.Copy(encoder, c.opts.Progress.reader(input))
+	if err != nil {
+		encoder.Close()
+		return fmt.Errorf("failed to compress data: %w", err)
+	}
+	// Close writes the end of the frame, the output is truncated when it
+	// fails.
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to compress data: %w", err)
+	}
+	return nil
+}
+
+// CompressFile compresses inputFile into outputFile. In block mode the frames
+// are written straight into outputFile in order.
+func (c *Compressor) CompressFile(inputFile, outputFile string) error {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", withKind(err, ErrIO))
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", withKind(err, ErrIO))
+	}
+
+	if err := c.Compress(input, output); err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", withKind(err, ErrIO))
+	}
+	return nil
+}
+
+// compressBlockStream reads chunks of ChunkSize from input, compresses them
+// into frames on Threads workers, which take the next chunk as soon as they
+// are done, and writes the frames in the original order. With Seekable
+// the seek table is written after the last frame. With Adapt the level
+// changes between frames. With Prime a meta frame comes first and every
+// frame is primed with the input before it. With SelfDict the dictionary
+// trained on the input comes first.
+func (c *Compressor) compressBlockStream(input io.Reader, output io.Writer) error {
+	opts := c.opts
+	if opts.SelfDict {
+		var err error
+		if opts.Dict, input, err = c.trainSelfDict(input); err != nil {
+			return err
+		}
+	}
+	input, output = c.opts.Progress.reader(ioReader{input}), c.opts.Progress.writer(ioWriter{output})
+	var encoder BlockEncoder
+	var adapt *adapter
+	if opts.Adapt {
+		adapt = newAdapter(opts)
+		encoder = adapt
+	} else {
+		var err error
+		encoder, err = opts.backend().NewBlockEncoder(opts)
+		if err != nil {
+			return fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+	}
+	defer encoder.Close()
+
+	if opts.SelfDict && opts.Dict != nil {
+		if _, err := output.Write(appendDictFrame(nil, opts.Dict)); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+
+	prime := c.opts.Prime
+	primer, _ := encoder.(primingEncoder)
+	if prime != 0 {
+		if primer == nil {
+			return errNoPrime
+		}
+		if _, err := output.Write(appendPrimeFrame(nil, prime)); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+
+	frameSize := c.opts.frameSize()
+	var offset int64
+	var prev []byte
+	next := func() (*chunk, error) {
+		buf := make([]byte, c.opts.chunkSize())
+		start := time.Now()
+		n, err := io.ReadFull(input, buf)
+		adapt.addRead(time.Since(start))
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		ch := &chunk{in: buf[:n], offset: offset, prefix: prev}
+		offset += int64(n)
+		if prime != 0 && n >= prime {
+			prev = ch.in[n-prime:]
+		} else if prime != 0 {
+			// A chunk shorter than prime keeps the end of the one before.
+			prev = append(append([]byte(nil), prev[max(0, len(prev)+n-prime):]...), ch.in...)
+		}
+		return ch, nil
+	}
+	work := func(ch *chunk) {
+		for pos := 0; pos < len(ch.in) && ch.err == nil; pos += frameSize {
+			src := ch.in[pos:min(len(ch.in), pos+frameSize)]
+			size := len(ch.out)
+			if prime != 0 {
+				ch.out, ch.err = primer.EncodePrimed(ch.history(pos, prime), src, ch.out)
+			} else {
+				ch.out, ch.err = encoder.Encode(src, ch.out)
+			}
+			ch.frames = append(ch.frames, len(ch.out)-size)
+		}
+	}
+	table := seekTable{HasChecksum: c.opts.SeekChecksums}
+	empty := true
+	write := func(ch *chunk) error {
+		empty = false
+		start := time.Now()
+		if _, err := output.Write(ch.out); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		adapt.addWrite(time.Since(start))
+		out, in := ch.out, ch.in
+		for _, size := range ch.frames {
+			n := min(len(in), frameSize)
+			table.add(out[:size], in[:n])
+			out, in = out[size:], in[n:]
+		}
+		return nil
+	}
+
+	if err := runOrdered(c.opts.Threads, c.opts.Progress, next, work, write); err != nil {
+		return err
+	}
+	// Empty input still makes one frame.
+	if empty {
+		frame, err := encoder.Encode(nil, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := output.Write(frame); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		table.add(frame, nil)
+	}
+	if c.opts.Seekable {
+		return table.writeTo(output)
+	}
+	return nil
+}
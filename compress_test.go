@@ -0,0 +1,46 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// failWriter takes n bytes, then fails.
+type failWriter struct {
+	n int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWriteFailed
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+// A failed write fails the compression, also when it is the last flush.
+func TestCompressWriteError(t *testing.T) {
+	data := testData(100 << 10)
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeStream},
+		{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 16 << 10},
+	} {
+		c, err := NewCompressor(opts)
+		if err != nil {
+			t.Fatalf("NewCompressor: %v", err)
+		}
+		// The whole input fits in the buffers of the stream encoder, only
+		// Close writes it.
+		for _, n := range []int{0, 100} {
+			err := c.Compress(bytes.NewReader(data), &failWriter{n})
+			if !errors.Is(err, errWriteFailed) || !errors.Is(err, ErrIO) {
+				t.Errorf("%s mode, failing after %d bytes: got %v, want the write error", opts.Mode, n, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package gozstd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxParallelFrameSize is the default largest frame content size the
+// parallel decompressor keeps in memory. Frames from block mode are 1 MB.
+const maxParallelFrameSize = 64 * oneMB
+
+// Decompressor decompresses zstd data.
+type Decompressor struct {
+	opts Options
+}
+
+// NewDecompressor returns a Decompressor. Only Threads, Dict, MaxMemory and
+// Progress are used from opts.
+func NewDecompressor(opts Options) (*Decompressor, error) {
+	if opts.Threads < 1 {
+		return nil, withKind(fmt.Errorf("%w: %d", ErrInvalidThreads, opts.Threads), ErrUsage)
+	}
+	if opts.MaxMemory != 0 && opts.MaxMemory < zstd.MinWindowSize {
+		return nil, withKind(fmt.Errorf("decoder memory limit must be at least %d", zstd.MinWindowSize), ErrUsage)
+	}
+	if err := checkDict(opts.Dict); err != nil {
+		return nil, withKind(err, ErrUsage)
+	}
+	return &Decompressor{opts: opts}, nil
+}
+
+// maxFrameSize is the largest frame the parallel decompressor keeps in
+// memory. With MaxMemory it is shared by the frames in flight.
+func (d *Decompressor) maxFrameSize() uint64 {
+	if d.opts.MaxMemory != 0 {
+		return d.opts.MaxMemory / uint64(2*d.opts.Threads)
+	}
+	return maxParallelFrameSize
+}
+
+// Decompress decompresses input to output. When Threads is more than 1 and
+// the input starts with a frame that records a small content size, as the
+// ones written by block mode, the frames are decoded in parallel. Archives
+// made with Prime are decoded one frame after another, the dictionary of
+// archives made with SelfDict is loaded from the archive.
+func (d *Decompressor) Decompress(input io.Reader, output io.Writer) (err error) {
+	defer func() { err = decodeError(err) }()
+	input, output = d.opts.Progress.reader(ioReader{input}), d.opts.Progress.writer(ioWriter{output})
+	br := bufio.NewReaderSize(input, 1<<16)
+	meta, err := readMeta(br)
+	if err != nil {
+		return err
+	}
+	d = d.withMeta(meta)
+	if meta.prime != 0 {
+		return d.decompressPrimed(br, output, meta)
+	}
+	if d.opts.Threads > 1 && hasSmallFrames(br, d.maxFrameSize()) {
+		// From a frame too large for it on the stream decoder goes on.
+		if err := d.decompressParallel(br, output, meta); err != errLargeFrame {
+			return err
+		}
+	}
+
+	decoder, err := zstd.NewReader(br, d.opts.decoderOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	_, err = io.Copy(output, decoder)
+	if err != nil {
+		return fmt.Errorf("failed to decompress data: %w", err)
+	}
+
+	return nil
+}
+
+// hasSmallFrames peeks at the first frame header of br and reports whether
+// it is worth splitting the input into frames of at most limit bytes.
+func hasSmallFrames(br *bufio.Reader, limit uint64) bool {
+	buf, _ := br.Peek(zstd.HeaderMaxSize)
+	var h zstd.Header
+	if err := h.Decode(buf); err != nil {
+		return false
+	}
+	return !h.Skippable && h.HasFCS && h.FrameContentSize <= limit
+}
+
+// errLargeFrame stops the parallel decoders at a frame which is too large to
+// be held in memory whole.
+var errLargeFrame = errors.New("frame too large to decode in parallel")
+
+// frameFits peeks at the next frame header of br and reports whether the
+// frame can be held in memory: its content size is known and at most limit.
+// Skippable frames are not decoded and fit. At the end of br or on a bad
+// header it reports true, the scanner reports those.
+func frameFits(br *bufio.Reader, limit uint64) bool {
+	buf, _ := br.Peek(zstd.HeaderMaxSize)
+	var h zstd.Header
+	if err := h.Decode(buf); err != nil {
+		return true
+	}
+	return h.Skippable || (h.HasFCS && h.FrameContentSize <= limit)
+}
+
+// decompressParallel finds the frame boundaries of input, decodes the frames
+// on Threads workers and writes them in order. It stops with errLargeFrame
+// before a frame larger than maxFrameSize, input is then at its start.
+func (d *Decompressor) decompressParallel(input *bufio.Reader, output io.Writer, meta archiveMeta) error {
+	decoder, err := zstd.NewReader(nil, append(d.opts.decoderOptions(), zstd.WithDecoderConcurrency(d.opts.Threads))...)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	scanner := meta.newFrameScanner(input)
+	next := func() (*chunk, error) {
+		if !frameFits(input, d.maxFrameSize()) {
+			return nil, errLargeFrame
+		}
+		info, raw, err := scanner.next(true)
+		if err != nil {
+			return nil, err
+		}
+		ch := &chunk{in: raw, offset: info.Offset}
+		// frameFits checked the size, it is up to maxFrameSize.
+		if info.ContentSize > 0 {
+			ch.out = make([]byte, 0, info.ContentSize)
+		}
+		return ch, nil
+	}
+	work := func(ch *chunk) {
+		ch.out, ch.err = decoder.DecodeAll(ch.in, ch.out)
+		if ch.err != nil {
+			ch.err = &FrameError{Frame: meta.frames + ch.index, Offset: ch.offset, Err: ch.err}
+		}
+		ch.in = nil
+	}
+	write := func(ch *chunk) error {
+		if _, err := output.Write(ch.out); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	}
+
+	return runOrdered(d.opts.Threads, d.opts.Progress, next, work, write)
+}
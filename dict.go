@@ -0,0 +1,41 @@
+package gozstd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDictSize is the dictionary size used by zstd --train.
+const DefaultMaxDictSize = 112640
+
+// ErrNoSamples is returned by TrainDict when there is nothing to train on.
+var ErrNoSamples = errors.New("no samples to train the dictionary")
+
+// TrainDict builds a zstd dictionary of at most maxSize bytes from samples,
+// tuned for the compression level. The result can be used as Options.Dict.
+func TrainDict(samples [][]byte, maxSize, level int) (d []byte, err error) {
+	if len(samples) == 0 {
+		return nil, withKind(ErrNoSamples, ErrUsage)
+	}
+	if level < 1 || level > 19 {
+		return nil, withKind(fmt.Errorf("%w: %d", ErrInvalidLevel, level), ErrUsage)
+	}
+	// The builder panics on some inputs, like samples of only zeros.
+	defer func() {
+		if r := recover(); r != nil {
+			d, err = nil, fmt.Errorf("failed to train dictionary: %v", r)
+		}
+	}()
+	d, err = dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: maxSize,
+		HashBytes:   6,
+		ZstdLevel:   zstd.EncoderLevelFromZstd(level),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to train dictionary: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,85 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testDict trains a dictionary on records like testData.
+func testDict(t testing.TB) []byte {
+	t.Helper()
+	var samples [][]byte
+	for i := 0; i < 200; i++ {
+		samples = append(samples, testData(1000+i))
+	}
+	dict, err := TrainDict(samples, 16<<10, 3)
+	if err != nil {
+		t.Fatalf("TrainDict: %v", err)
+	}
+	return dict
+}
+
+func TestTrainDict(t *testing.T) {
+	dict := testDict(t)
+	if len(dict) == 0 || len(dict) > 16<<10 {
+		t.Fatalf("dictionary of %d bytes, want up to %d", len(dict), 16<<10)
+	}
+	if err := checkDict(dict); err != nil {
+		t.Fatalf("not a zstd dictionary: %v", err)
+	}
+	if _, err := TrainDict(nil, DefaultMaxDictSize, 3); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("no samples: got %v, want ErrNoSamples", err)
+	}
+	// The builder panics on samples of only zeros.
+	if _, err := TrainDict([][]byte{make([]byte, 1000), make([]byte, 1000)}, DefaultMaxDictSize, 3); err == nil {
+		t.Errorf("samples of zeros did not fail")
+	}
+}
+
+func TestDictRoundTrip(t *testing.T) {
+	dict := testDict(t)
+	data := testData(300 << 10)
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeStream, Dict: dict},
+		{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 16 << 10, Dict: dict},
+	} {
+		archive := compressBytes(t, opts, data)
+		without := compressBytes(t, Options{Level: 3, Threads: 2, Mode: opts.Mode, FrameSize: opts.FrameSize}, data)
+		if opts.Mode == ModeBlock && len(archive) >= len(without) {
+			t.Errorf("%s mode: %d bytes with the dictionary, %d without", opts.Mode, len(archive), len(without))
+		}
+		for _, threads := range []int{1, 4} {
+			if got := decompressBytes(t, Options{Threads: threads, Dict: dict}, archive); !bytes.Equal(got, data) {
+				t.Errorf("%s mode, %d threads: got %d bytes back", opts.Mode, threads, len(got))
+			}
+		}
+	}
+}
+
+// The dictionary works with the zstd tool too.
+func TestDictZstdCompat(t *testing.T) {
+	zstdPath, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd not found")
+	}
+	dict := testDict(t)
+	name := filepath.Join(t.TempDir(), "dict")
+	if err := os.WriteFile(name, dict, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data := testData(100 << 10)
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 16 << 10, Dict: dict}, data)
+	cmd := exec.Command(zstdPath, "-d", "-c", "-q", "-D", name)
+	cmd.Stdin = bytes.NewReader(archive)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("zstd -d -D: %v", err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("zstd -d -D returned %d bytes", len(out))
+	}
+}
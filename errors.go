@@ -0,0 +1,98 @@
+package gozstd
+
+import (
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// kindError adds one of ErrUsage, ErrIO, ErrCorrupt or ErrChecksum to err
+// for errors.Is, the message stays the same.
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.err, e.kind}
+}
+
+// withKind returns err marked with kind, unless it is nil or has a kind
+// already.
+func withKind(err, kind error) error {
+	if err == nil || errorKind(err) != nil {
+		return err
+	}
+	return &kindError{err, kind}
+}
+
+// errorKind returns the kind err was marked with, or nil.
+func errorKind(err error) error {
+	for _, kind := range []error{ErrUsage, ErrIO, ErrCorrupt, ErrChecksum} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// decodeError gives a kind to an error of the decoder. The errors of the
+// input and the output are already marked with ErrIO by ioReader and
+// ioWriter, so the rest is about the data. A missing seek table is not an
+// error of the data, it stays as it is.
+func decodeError(err error) error {
+	switch {
+	case errors.Is(err, ErrNoSeekTable):
+		return err
+	case errors.Is(err, zstd.ErrCRCMismatch):
+		return withKind(err, ErrChecksum)
+	case errors.Is(err, zstd.ErrWindowSizeExceeded), errors.Is(err, zstd.ErrDecoderSizeExceeded):
+		// MaxMemory is too small for the archive.
+		return withKind(err, ErrUsage)
+	case errors.Is(err, zstd.ErrUnknownDictionary):
+		// The archive was made with a dictionary which was not given.
+		return withKind(err, ErrUsage)
+	}
+	return withKind(err, ErrCorrupt)
+}
+
+// ioReader marks the errors of r with ErrIO, io.EOF stays as it is.
+type ioReader struct {
+	r io.Reader
+}
+
+func (r ioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = withKind(err, ErrIO)
+	}
+	return n, err
+}
+
+// ioReaderAt is ioReader for an io.ReaderAt.
+type ioReaderAt struct {
+	r io.ReaderAt
+}
+
+func (r ioReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		err = withKind(err, ErrIO)
+	}
+	return n, err
+}
+
+// ioWriter marks the errors of w with ErrIO.
+type ioWriter struct {
+	w io.Writer
+}
+
+func (w ioWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	return n, withKind(err, ErrIO)
+}
@@ -0,0 +1,59 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestUsageErrors(t *testing.T) {
+	for _, opts := range []Options{
+		{Level: 0, Threads: 1},
+		{Level: 3, Threads: 0},
+		{Level: 3, Threads: 1, Mode: ModeStream, Seekable: true},
+		{Level: 3, Threads: 1, WindowSize: 3000},
+		{Level: 3, Threads: 1, Dict: []byte("not a dictionary"), Backend: pureGoBackend{}},
+	} {
+		if _, err := NewCompressor(opts); !errors.Is(err, ErrUsage) {
+			t.Errorf("NewCompressor(%+v): got %v, want ErrUsage", opts, err)
+		}
+	}
+	if _, err := NewDecompressor(Options{Threads: 1, Dict: []byte("not a dictionary")}); !errors.Is(err, ErrUsage) || !errors.Is(err, ErrInvalidDict) {
+		t.Errorf("NewDecompressor with a bad dictionary: got %v, want ErrInvalidDict", err)
+	}
+	if _, err := TrainDict([][]byte{[]byte("sample")}, DefaultMaxDictSize, 22); !errors.Is(err, ErrUsage) || !errors.Is(err, ErrInvalidLevel) {
+		t.Errorf("TrainDict at level 22: got %v, want ErrInvalidLevel", err)
+	}
+	if _, err := TrainDict(nil, DefaultMaxDictSize, 3); !errors.Is(err, ErrUsage) || !errors.Is(err, ErrNoSamples) {
+		t.Errorf("TrainDict without samples: got %v, want ErrNoSamples", err)
+	}
+}
+
+// Options the backend cannot do are rejected before anything is compressed.
+func TestUnsupportedOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeBlock, WindowSize: 2 * oneMB},
+		{Level: 3, Threads: 2, Mode: ModeBlock, Prime: 64 << 10},
+	} {
+		_, err := NewCompressor(opts)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, errors.ErrUnsupported) || !errors.Is(err, ErrUsage) {
+			t.Errorf("NewCompressor(%+v): got %v, want an unsupported usage error", opts, err)
+		}
+	}
+}
+
+func TestMissingDict(t *testing.T) {
+	dict := testDict(t)
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeStream, Dict: dict}, testData(5000))
+	d, _ := NewDecompressor(Options{Threads: 2})
+	if err := d.Decompress(bytes.NewReader(archive), io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Decompress without the dictionary: got %v, want ErrUsage", err)
+	}
+	if err := d.Test(bytes.NewReader(archive)); !errors.Is(err, ErrUsage) {
+		t.Errorf("Test without the dictionary: got %v, want ErrUsage", err)
+	}
+}
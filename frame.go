@@ -0,0 +1,252 @@
+package gozstd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	frameMagic         = 0xFD2FB528
+	skippableMagic     = 0x184D2A50
+	skippableMagicMask = 0xFFFFFFF0
+	maxBlockSize       = 128 << 10
+)
+
+// FrameInfo describes one frame of a zstd stream.
+type FrameInfo struct {
+	Offset      int64 // Offset of the frame in the compressed stream
+	Size        int64 // Compressed size including header and checksum
+	Skippable   bool
+	SeekTable   bool // Skippable frame holding a seek table
+	Magic       uint32
+	ContentSize int64 // Decompressed size, -1 if the header does not record it
+	WindowSize  uint64
+	DictID      uint32
+	HasChecksum bool
+}
+
+// FrameError reports the frame of an archive which could not be decoded.
+type FrameError struct {
+	Frame  int   // Index of the frame, skippable frames are counted too
+	Offset int64 // Offset of the frame in the compressed input
+	Err    error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("frame %d at offset %d: %v", e.Frame, e.Offset, e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// frameScanner walks the frames of a zstd stream by parsing frame and block
+// headers only, nothing is decompressed. When sink is set every byte of the
+// stream is copied to it.
+type frameScanner struct {
+	r      *bufio.Reader
+	offset int64
+	raw    []byte
+	frames int
+	sink   io.Writer
+}
+
+func newFrameScanner(r io.Reader) *frameScanner {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 1<<16)
+	}
+	return &frameScanner{r: br}
+}
+
+// read consumes n bytes. They are appended to s.raw when keep is set.
+func (s *frameScanner) read(n int, keep bool) error {
+	var err error
+	switch {
+	case keep:
+		// n comes from the stream, the buffer only grows with what is read.
+		start := len(s.raw)
+		buf := bytes.NewBuffer(s.raw)
+		var m int64
+		m, err = io.CopyN(buf, s.r, int64(n))
+		if err == io.EOF && m < int64(n) {
+			err = io.ErrUnexpectedEOF
+		}
+		s.raw = buf.Bytes()
+		if err == nil && s.sink != nil {
+			_, err = s.sink.Write(s.raw[start:])
+		}
+	case s.sink != nil:
+		var m int64
+		m, err = io.CopyN(s.sink, s.r, int64(n))
+		if err == io.EOF && m < int64(n) {
+			err = io.ErrUnexpectedEOF
+		}
+	default:
+		var m int
+		m, err = s.r.Discard(n)
+		if err == io.EOF && m < n {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	s.offset += int64(n)
+	return err
+}
+
+// next returns the next frame. When keep is true the raw bytes of the frame
+// are returned too, otherwise they are discarded. It returns io.EOF at the
+// end of the stream.
+func (s *frameScanner) next(keep bool) (FrameInfo, []byte, error) {
+	info := FrameInfo{Offset: s.offset, ContentSize: -1}
+	s.raw = nil
+
+	if _, err := s.r.Peek(1); err == io.EOF {
+		return info, nil, io.EOF
+	}
+	s.frames++
+	if err := s.read(4, true); err != nil {
+		return info, nil, s.frameError(info, err)
+	}
+	info.Magic = binary.LittleEndian.Uint32(s.raw)
+	if !keep {
+		s.raw = s.raw[:0]
+	}
+
+	switch {
+	case info.Magic&skippableMagicMask == skippableMagic:
+		info.Skippable = true
+		if err := s.read(4, true); err != nil {
+			return info, nil, s.frameError(info, err)
+		}
+		size := binary.LittleEndian.Uint32(s.raw[len(s.raw)-4:])
+		if !keep {
+			s.raw = s.raw[:0]
+		}
+		// Keep the payload of a seek table to look at its footer.
+		isTable := info.Magic == seekTableMagic
+		if err := s.read(int(size), keep || isTable); err != nil {
+			return info, nil, s.frameError(info, err)
+		}
+		if isTable && size >= seekFooterSize {
+			info.SeekTable = binary.LittleEndian.Uint32(s.raw[len(s.raw)-4:]) == seekableMagic
+		}
+		if !keep {
+			s.raw = s.raw[:0]
+		}
+	case info.Magic == frameMagic:
+		if err := s.readFrame(&info, keep); err != nil {
+			return info, nil, s.frameError(info, err)
+		}
+	default:
+		return info, nil, s.frameError(info, fmt.Errorf("unknown frame magic %#08x", info.Magic))
+	}
+
+	info.Size = s.offset - info.Offset
+	if !keep {
+		return info, nil, nil
+	}
+	return info, s.raw, nil
+}
+
+func (s *frameScanner) readFrame(info *FrameInfo, keep bool) error {
+	start := len(s.raw)
+	if err := s.read(1, true); err != nil {
+		return err
+	}
+	desc := s.raw[start]
+	fcsFlag := desc >> 6
+	singleSegment := desc&(1<<5) != 0
+	info.HasChecksum = desc&(1<<2) != 0
+	if desc&(1<<3) != 0 {
+		return errors.New("reserved bit set in frame header")
+	}
+
+	dictSize := [4]int{0, 1, 2, 4}[desc&3]
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && singleSegment {
+		fcsSize = 1
+	}
+	windowSize := 1
+	if singleSegment {
+		windowSize = 0
+	}
+	if err := s.read(windowSize+dictSize+fcsSize, true); err != nil {
+		return err
+	}
+	h := s.raw[start+1:]
+
+	if !singleSegment {
+		exponent, mantissa := uint64(h[0]>>3), uint64(h[0]&7)
+		base := uint64(1) << (10 + exponent)
+		info.WindowSize = base + base/8*mantissa
+		h = h[1:]
+	}
+	for i := dictSize - 1; i >= 0; i-- {
+		info.DictID = info.DictID<<8 | uint32(h[i])
+	}
+	h = h[dictSize:]
+	switch fcsSize {
+	case 1:
+		info.ContentSize = int64(h[0])
+	case 2:
+		info.ContentSize = int64(binary.LittleEndian.Uint16(h)) + 256
+	case 4:
+		info.ContentSize = int64(binary.LittleEndian.Uint32(h))
+	case 8:
+		info.ContentSize = int64(binary.LittleEndian.Uint64(h))
+	}
+	if singleSegment {
+		info.WindowSize = uint64(info.ContentSize)
+	}
+	if !keep {
+		s.raw = s.raw[:start]
+	}
+
+	for {
+		blockStart := len(s.raw)
+		if err := s.read(3, true); err != nil {
+			return err
+		}
+		b := s.raw[blockStart:]
+		bh := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+		if !keep {
+			s.raw = s.raw[:blockStart]
+		}
+		last := bh&1 != 0
+		size := int(bh >> 3)
+		switch (bh >> 1) & 3 {
+		case 1: // RLE block, a single byte follows
+			size = 1
+		case 3:
+			return errors.New("reserved block type")
+		}
+		if size > maxBlockSize {
+			return fmt.Errorf("block size %d too large", size)
+		}
+		if err := s.read(size, keep); err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+	}
+
+	if info.HasChecksum {
+		if err := s.read(4, keep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frameError wraps err in a FrameError for the frame described by info.
+func (s *frameScanner) frameError(info FrameInfo, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("truncated frame: %w", io.ErrUnexpectedEOF)
+	}
+	return &FrameError{Frame: s.frames - 1, Offset: info.Offset, Err: err}
+}
@@ -0,0 +1,152 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+// testData returns n bytes of text like input, the same for the same n.
+func testData(n int) []byte {
+	words := []string{"zstd", "frame", "block", "seek", "table", "chunk", "window", "level", "the", "a", "of", "\n"}
+	r := rand.New(rand.NewPCG(uint64(n), 1))
+	var b bytes.Buffer
+	for b.Len() < n {
+		b.WriteString(words[r.IntN(len(words))])
+		b.WriteByte(' ')
+		if r.IntN(8) == 0 {
+			b.WriteString(string(rune('0' + r.IntN(10))))
+		}
+	}
+	return b.Bytes()[:n]
+}
+
+// compressBytes compresses data with opts. A test of options the default
+// backend cannot do is skipped.
+func compressBytes(t testing.TB, opts Options, data []byte) []byte {
+	t.Helper()
+	c, err := NewCompressor(opts)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skipf("%s: %v", DefaultBackend().Name(), err)
+	}
+	if err != nil {
+		t.Fatalf("NewCompressor: %v", err)
+	}
+	var out bytes.Buffer
+	if err := c.Compress(bytes.NewReader(data), &out); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	return out.Bytes()
+}
+
+func decompressBytes(t testing.TB, opts Options, archive []byte) []byte {
+	t.Helper()
+	d, err := NewDecompressor(opts)
+	if err != nil {
+		t.Fatalf("NewDecompressor: %v", err)
+	}
+	var out bytes.Buffer
+	if err := d.Decompress(bytes.NewReader(archive), &out); err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		size int
+	}{
+		{"stream", Options{Level: 3, Threads: 2, Mode: ModeStream}, 3 * oneMB},
+		{"block", Options{Level: 3, Threads: 4, Mode: ModeBlock}, 3*oneMB + 17},
+		{"frame size", Options{Level: 3, Threads: 4, Mode: ModeBlock, FrameSize: 64 << 10}, oneMB + 5},
+		{"chunk size", Options{Level: 3, Threads: 4, Mode: ModeBlock, ChunkSize: 256 << 10, FrameSize: 32 << 10}, oneMB + 5},
+		{"window", Options{Level: 3, Threads: 2, Mode: ModeBlock, WindowSize: 2 * oneMB}, 5 * oneMB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, size := range []int{0, 1, 100, tt.size} {
+				data := testData(size)
+				archive := compressBytes(t, tt.opts, data)
+				if len(archive) == 0 {
+					t.Fatalf("%d bytes: empty archive", size)
+				}
+				for _, threads := range []int{1, 4} {
+					if got := decompressBytes(t, Options{Threads: threads}, archive); !bytes.Equal(got, data) {
+						t.Fatalf("%d bytes, %d threads: got %d bytes back", size, threads, len(got))
+					}
+				}
+			}
+		})
+	}
+}
+
+// A frame too large for the parallel decoder hands the rest of the input to
+// the stream decoder.
+func TestLargeFrameFallback(t *testing.T) {
+	small := testData(oneMB)
+	large := testData(2 * oneMB)
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, small)
+	archive = append(archive, compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: oneMB}, large)...)
+	want := append(append([]byte(nil), small...), large...)
+
+	// Frames up to 1 MB / 4 fit.
+	if got := decompressBytes(t, Options{Threads: 2, MaxMemory: oneMB}, archive); !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes back, want %d", len(got), len(want))
+	}
+}
+
+func TestCorruptFrame(t *testing.T) {
+	data := testData(oneMB)
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, data)
+	archive[len(archive)/2] ^= 0xFF
+	d, _ := NewDecompressor(Options{Threads: 2})
+	err := d.Decompress(bytes.NewReader(archive), &bytes.Buffer{})
+	if !errors.Is(err, ErrCorrupt) && !errors.Is(err, ErrChecksum) {
+		t.Fatalf("Decompress: got %v, want a corrupt or checksum error", err)
+	}
+	err = d.Decompress(bytes.NewReader(archive[:len(archive)-3]), &bytes.Buffer{})
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Decompress of a truncated archive: got %v, want ErrCorrupt", err)
+	}
+}
+
+// fuzzSeeds adds archives of every block option the default backend can do,
+// and broken ones, to f. They are small so the fuzzer can minimize what it
+// finds quickly.
+func fuzzSeeds(f *testing.F) {
+	data := testData(16 << 10)
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeStream},
+		{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 4 << 10},
+		{Level: 3, Threads: 2, Mode: ModeBlock, Seekable: true, SeekChecksums: true, FrameSize: 4 << 10},
+		{Level: 3, Threads: 2, Mode: ModeBlock, Prime: 2 << 10, FrameSize: 4 << 10},
+	} {
+		if _, err := NewCompressor(opts); err != nil {
+			continue
+		}
+		archive := compressBytes(f, opts, data)
+		f.Add(archive)
+		f.Add(archive[:len(archive)/2])
+		f.Add(compressBytes(f, opts, nil))
+	}
+	f.Add(appendDictFrame(nil, []byte("not a dictionary")))
+	f.Add(appendPrimeFrame(nil, 1<<30))
+	f.Add([]byte{})
+}
+
+// fuzzOptions keeps the memory a fuzzed archive can ask for small.
+var fuzzOptions = Options{Threads: 2, MaxMemory: 16 * oneMB}
+
+func FuzzDecompress(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, archive []byte) {
+		d, _ := NewDecompressor(fuzzOptions)
+		var out bytes.Buffer
+		if err := d.Decompress(bytes.NewReader(archive), &out); err != nil && errorKind(err) == nil {
+			t.Fatalf("error without a kind: %v", err)
+		}
+	})
+}
@@ -2,6 +2,8 @@ module gozstd
 
 go 1.22.2
 
-require github.com/klauspost/compress v1.17.9
-
-require github.com/DataDog/zstd v1.5.6 // indirect
+require (
+	github.com/DataDog/zstd v1.5.6
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/klauspost/compress v1.17.9
+)
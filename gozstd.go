@@ -0,0 +1,266 @@
+// Package gozstd is a small zstd compression library built on top of
+// github.com/klauspost/compress/zstd. It adds a block mode which splits the
+// input into chunks and compresses them on several goroutines, producing a
+// standard multi-frame zstd file.
+package gozstd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const oneMB = 1 << 20
+
+// Mode selects how the Compressor uses the available threads.
+type Mode int
+
+const (
+	// ModeStream uses a single streaming encoder. The klauspost encoder only
+	// uses up to 2 threads in this mode.
+	ModeStream Mode = iota
+	// ModeBlock splits the input into chunks and compresses them in
+	// parallel. It is only worth it for compression level higher than 9.
+	ModeBlock
+	// ModeAuto picks ModeStream or ModeBlock for every input, see
+	// Compressor.ChooseMode.
+	ModeAuto
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeStream:
+		return "stream"
+	case ModeBlock:
+		return "block"
+	case ModeAuto:
+		return "auto"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Options configures a Compressor or Decompressor.
+type Options struct {
+	Level   int  // Compression level 1-19, up to Backend.MaxLevel with Ultra
+	Threads int  // Number of threads, used by block mode
+	Mode    Mode // Stream, block or auto mode
+
+	// Seekable appends a seek table in the zstd seekable format to the
+	// output of block mode, so readers can jump to any offset.
+	Seekable bool
+	// SeekChecksums stores the content checksum of every frame in the seek table.
+	SeekChecksums bool
+
+	// ChunkSize is the input a block mode worker takes at a time, it is
+	// compressed into frames of FrameSize. Zero means one frame. The workers
+	// take chunks from a shared queue so a slow part of the input does not
+	// hold up the others. Up to 2*Threads chunks are kept in memory.
+	ChunkSize int
+	// FrameSize is the input block mode puts in one frame. Every frame
+	// starts without history, so bigger frames compress better. Zero means
+	// 1 MB, or WindowSize if larger. Frames up to 64 MB are still decoded in
+	// parallel.
+	FrameSize int
+
+	// Prime gives every block mode frame up to Prime bytes of the input
+	// before it as history, so matches can cross the frame boundaries like
+	// with one stream. The frames still compress in parallel, but they have
+	// to be decoded one after another and only by this package, other zstd
+	// decoders report a missing dictionary. It can be up to WindowSize, or
+	// 8 MB, and cannot be used with Seekable or Dict.
+	Prime int
+
+	// WindowSize is the encoder window in bytes, a power of 2. Zero keeps the
+	// default of the level. In block mode every frame holds WindowSize bytes
+	// of input (at least 1 MB), unless FrameSize is set, so matches can reach
+	// that far back.
+	WindowSize int
+	// MaxMemory limits the window size the decoder accepts, zero keeps the
+	// default of 512 MB. It also bounds the frames decoded in parallel.
+	MaxMemory uint64
+
+	// Adapt changes the level between the frames of block mode, within
+	// AdaptMin and AdaptMax, so the compression keeps up with the input and
+	// the output. Zero AdaptMin and AdaptMax mean 1 and 19.
+	Adapt    bool
+	AdaptMin int
+	AdaptMax int
+
+	// Ultra allows levels above 19 when the backend supports them.
+	Ultra bool
+	// Backend is the encoder implementation, DefaultBackend when nil.
+	Backend Backend
+
+	// Dict is a zstd dictionary, as made by TrainDict or zstd --train, used
+	// by both the encoder and the decoder.
+	Dict []byte
+	// SelfDict trains a dictionary on samples of the input and compresses
+	// every block mode frame with it. The dictionary is stored in the
+	// archive, where the Decompressor finds it, but other zstd decoders
+	// report a missing dictionary. It cannot be used with Seekable, Prime or
+	// Dict.
+	SelfDict bool
+
+	// Progress, when not nil, counts the bytes read and written.
+	Progress *Progress
+}
+
+// DefaultOptions returns the options used by the command line tool.
+func DefaultOptions() Options {
+	return Options{Level: 3, Threads: 2, Mode: ModeAuto}
+}
+
+// The errors returned by this package match one of these kinds with
+// errors.Is, so a caller can tell a bad archive from a full disk.
+var (
+	// ErrUsage is matched by invalid Options, as returned by NewCompressor
+	// and NewDecompressor, and by options which do not work with the backend.
+	ErrUsage = errors.New("invalid usage")
+	// ErrIO is matched when reading the input or writing the output fails.
+	ErrIO = errors.New("input/output error")
+	// ErrCorrupt is matched when the input is not valid zstd data.
+	ErrCorrupt = errors.New("corrupt input")
+	// ErrChecksum is matched when the content of a frame does not match its checksum.
+	ErrChecksum = errors.New("checksum mismatch")
+)
+
+var (
+	// ErrInvalidLevel is returned when the compression level is out of range.
+	ErrInvalidLevel = errors.New("compression level must be between 1 and 19, or up to 22 with ultra and the cgo_zstd build")
+	// ErrInvalidThreads is returned when the number of threads is less than 1.
+	ErrInvalidThreads = errors.New("number of threads must be at least 1")
+	// ErrInvalidChunkSize is returned when the chunk or frame size is negative, or frames are too large for the seek table.
+	ErrInvalidChunkSize = errors.New("invalid chunk or frame size")
+	// ErrInvalidMode is returned for an unknown Mode.
+	ErrInvalidMode = errors.New("unknown compression mode")
+	// ErrInvalidWindow is returned when the window size is not a power of 2 in the supported range.
+	ErrInvalidWindow = fmt.Errorf("window size must be a power of 2 between %d and %d", zstd.MinWindowSize, zstd.MaxWindowSize)
+	// ErrSeekableMode is returned when the seekable format is asked for outside of block mode.
+	ErrSeekableMode = errors.New("seekable format requires block mode")
+	// ErrAdaptMode is returned when Adapt is asked for outside of block mode.
+	ErrAdaptMode = errors.New("adaptive level requires block mode")
+	// ErrInvalidAdapt is returned when AdaptMin and AdaptMax are not a valid level range.
+	ErrInvalidAdapt = errors.New("adapt range must be valid levels with min not above max")
+	// ErrInvalidPrime is returned when Prime is out of range or used with an option it does not work with.
+	ErrInvalidPrime = errors.New("prime must be between 0 and the window size, in block mode without a seek table or dictionary")
+	// ErrSelfDictMode is returned when SelfDict is used outside of block mode or with an option it does not work with.
+	ErrSelfDictMode = errors.New("self dictionary requires block mode without a seek table, prime or dictionary")
+	// ErrInvalidDict is returned when Dict is not a zstd dictionary.
+	ErrInvalidDict = errors.New("invalid zstd dictionary")
+	// ErrNoSeekTable is returned when an archive does not end with a seek table.
+	ErrNoSeekTable = errors.New("no seek table found")
+	// ErrBadSeekTable is returned when the seek table does not match the archive.
+	ErrBadSeekTable = errors.New("invalid seek table")
+)
+
+func (o Options) backend() Backend {
+	if o.Backend != nil {
+		return o.Backend
+	}
+	return defaultBackend
+}
+
+func (o Options) validate() error {
+	maxLevel := 19
+	if o.Ultra {
+		maxLevel = o.backend().MaxLevel()
+	}
+	if o.Level < 1 || o.Level > maxLevel {
+		return fmt.Errorf("%w: %d", ErrInvalidLevel, o.Level)
+	}
+	if o.Threads < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidThreads, o.Threads)
+	}
+	if o.ChunkSize < 0 || o.FrameSize < 0 {
+		return fmt.Errorf("%w: chunk %d, frame %d", ErrInvalidChunkSize, o.ChunkSize, o.FrameSize)
+	}
+	if o.Mode != ModeStream && o.Mode != ModeBlock && o.Mode != ModeAuto {
+		return fmt.Errorf("%w: %d", ErrInvalidMode, o.Mode)
+	}
+	if o.Seekable && o.Mode == ModeStream {
+		return ErrSeekableMode
+	}
+	// The seek table stores the size of a frame in 32 bits.
+	if o.Seekable && o.frameSize() > math.MaxUint32 {
+		return fmt.Errorf("%w: frame %d is larger than the seek table can hold", ErrInvalidChunkSize, o.frameSize())
+	}
+	if o.Adapt {
+		if o.Mode == ModeStream {
+			return ErrAdaptMode
+		}
+		if o.AdaptMin < 0 || o.AdaptMax < 0 || o.AdaptMin > maxLevel || o.AdaptMax > maxLevel || (o.AdaptMax != 0 && o.AdaptMin > o.AdaptMax) {
+			return fmt.Errorf("%w: %d,%d", ErrInvalidAdapt, o.AdaptMin, o.AdaptMax)
+		}
+	}
+	if o.Prime != 0 && (o.Prime < 0 || o.Prime > o.primeMax() || o.Mode == ModeStream || o.Seekable || o.Dict != nil) {
+		return fmt.Errorf("%w: %d", ErrInvalidPrime, o.Prime)
+	}
+	if o.SelfDict && (o.Mode == ModeStream || o.Seekable || o.Prime != 0 || o.Dict != nil) {
+		return ErrSelfDictMode
+	}
+	if o.WindowSize != 0 && (o.WindowSize < zstd.MinWindowSize || o.WindowSize > zstd.MaxWindowSize || o.WindowSize&(o.WindowSize-1) != 0) {
+		return fmt.Errorf("%w: %d", ErrInvalidWindow, o.WindowSize)
+	}
+	if c, ok := o.backend().(optionChecker); ok {
+		return c.checkOptions(o)
+	}
+	return nil
+}
+
+// checkDict returns ErrInvalidDict when dict is set but is not a zstd
+// dictionary the klauspost encoder and decoder can load.
+func checkDict(dict []byte) error {
+	if dict == nil {
+		return nil
+	}
+	if _, err := zstd.InspectDictionary(dict); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDict, err)
+	}
+	return nil
+}
+
+// frameSize is the amount of input block mode puts in one frame.
+func (o Options) frameSize() int {
+	if o.FrameSize != 0 {
+		return o.FrameSize
+	}
+	return max(oneMB, o.WindowSize)
+}
+
+// primeMax is the largest Prime, the window size.
+func (o Options) primeMax() int {
+	if o.WindowSize != 0 {
+		return o.WindowSize
+	}
+	return defaultPrimeMax
+}
+
+// chunkSize is the amount of input a block mode worker takes at a time.
+func (o Options) chunkSize() int {
+	return max(o.frameSize(), o.ChunkSize)
+}
+
+func (o Options) encoderOptions() []zstd.EOption {
+	// Zero frames keep empty input a valid archive, zstd rejects an empty file.
+	options := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(o.Level)), zstd.WithZeroFrames(true)}
+	if o.Dict != nil {
+		options = append(options, zstd.WithEncoderDict(o.Dict))
+	}
+	if o.WindowSize != 0 {
+		options = append(options, zstd.WithWindowSize(o.WindowSize))
+	}
+	return options
+}
+
+func (o Options) decoderOptions() []zstd.DOption {
+	var options []zstd.DOption
+	if o.Dict != nil {
+		options = append(options, zstd.WithDecoderDicts(o.Dict))
+	}
+	if o.MaxMemory != 0 {
+		options = append(options, zstd.WithDecoderMaxMemory(o.MaxMemory), zstd.WithDecoderMaxWindow(o.MaxMemory))
+	}
+	return options
+}
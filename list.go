@@ -0,0 +1,78 @@
+package gozstd
+
+import (
+	"io"
+	"slices"
+)
+
+// ArchiveInfo sums up the frames of a zstd stream, see List.
+type ArchiveInfo struct {
+	Frames           int   // Number of zstd frames
+	SkippableFrames  int   // Number of skippable frames, the seek table included
+	CompressedSize   int64 // Size of the whole stream
+	DecompressedSize int64 // Only valid when ContentSizeKnown
+	ContentSizeKnown bool  // Every frame header records its content size
+	MaxWindowSize    uint64
+	DictIDs          []uint32 // Distinct dictionary IDs used by the frames
+	HasChecksum      bool     // Every frame has a content checksum
+	SeekTable        bool     // The stream has a seek table
+}
+
+// Ratio returns the compression ratio, or 0 when it is not known.
+func (a *ArchiveInfo) Ratio() float64 {
+	if !a.ContentSizeKnown || a.CompressedSize == 0 {
+		return 0
+	}
+	return float64(a.DecompressedSize) / float64(a.CompressedSize)
+}
+
+// ListFrames calls fn for every frame of input. Only headers are parsed,
+// nothing is decompressed.
+func ListFrames(input io.Reader, fn func(FrameInfo) error) error {
+	scanner := newFrameScanner(ioReader{input})
+	for {
+		info, _, err := scanner.next(false)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return decodeError(err)
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+}
+
+// List walks the frames of input and returns a summary of them. When fn is
+// not nil it is called for every frame too.
+func List(input io.Reader, fn func(FrameInfo)) (*ArchiveInfo, error) {
+	a := &ArchiveInfo{ContentSizeKnown: true, HasChecksum: true}
+	err := ListFrames(input, func(info FrameInfo) error {
+		if fn != nil {
+			fn(info)
+		}
+		a.CompressedSize += info.Size
+		if info.Skippable {
+			a.SkippableFrames++
+			a.SeekTable = a.SeekTable || info.SeekTable
+			return nil
+		}
+		a.Frames++
+		if info.ContentSize < 0 {
+			a.ContentSizeKnown = false
+		} else {
+			a.DecompressedSize += info.ContentSize
+		}
+		a.HasChecksum = a.HasChecksum && info.HasChecksum
+		a.MaxWindowSize = max(a.MaxWindowSize, info.WindowSize)
+		if info.DictID != 0 && !slices.Contains(a.DictIDs, info.DictID) {
+			a.DictIDs = append(a.DictIDs, info.DictID)
+		}
+		return nil
+	})
+	if a.Frames == 0 {
+		a.HasChecksum = false
+	}
+	return a, err
+}
@@ -0,0 +1,88 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	data := testData(256 << 10)
+	tests := []struct {
+		name      string
+		opts      Options
+		frames    int
+		skippable int
+	}{
+		{"block", Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, 4, 0},
+		{"seekable", Options{Level: 3, Threads: 2, Mode: ModeBlock, Seekable: true, FrameSize: 64 << 10}, 4, 1},
+		{"empty", Options{Level: 3, Threads: 2, Mode: ModeBlock}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := data
+			if tt.name == "empty" {
+				in = nil
+			}
+			archive := compressBytes(t, tt.opts, in)
+			var seen int
+			info, err := List(bytes.NewReader(archive), func(FrameInfo) { seen++ })
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if info.Frames != tt.frames || info.SkippableFrames != tt.skippable || seen != tt.frames+tt.skippable {
+				t.Errorf("got %d frames and %d skippable, %d seen, want %d and %d", info.Frames, info.SkippableFrames, seen, tt.frames, tt.skippable)
+			}
+			if info.CompressedSize != int64(len(archive)) {
+				t.Errorf("compressed size %d, want %d", info.CompressedSize, len(archive))
+			}
+			if !info.ContentSizeKnown || info.DecompressedSize != int64(len(in)) {
+				t.Errorf("content size %d known %v, want %d", info.DecompressedSize, info.ContentSizeKnown, len(in))
+			}
+			if info.SeekTable != tt.opts.Seekable {
+				t.Errorf("seek table %v, want %v", info.SeekTable, tt.opts.Seekable)
+			}
+			if len(in) > 0 && info.Ratio() <= 1 {
+				t.Errorf("ratio %.2f of text", info.Ratio())
+			}
+		})
+	}
+}
+
+func TestListFrames(t *testing.T) {
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, testData(200<<10))
+	var offset int64
+	err := ListFrames(bytes.NewReader(archive), func(info FrameInfo) error {
+		if info.Offset != offset {
+			t.Errorf("frame at %d, want %d", info.Offset, offset)
+		}
+		offset += info.Size
+		return nil
+	})
+	if err != nil || offset != int64(len(archive)) {
+		t.Errorf("ListFrames covered %d bytes of %d: %v", offset, len(archive), err)
+	}
+	stop := errors.New("stop")
+	if err := ListFrames(bytes.NewReader(archive), func(FrameInfo) error { return stop }); err != stop {
+		t.Errorf("the error of fn: got %v, want it back", err)
+	}
+	if _, err := List(bytes.NewReader(archive[:len(archive)-3]), nil); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("truncated archive: got %v, want ErrCorrupt", err)
+	}
+}
+
+func FuzzList(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, archive []byte) {
+		info, err := List(bytes.NewReader(archive), nil)
+		if err != nil {
+			if !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("got %v, want ErrCorrupt", err)
+			}
+			return
+		}
+		if info.CompressedSize != int64(len(archive)) {
+			t.Fatalf("List found %d bytes of %d", info.CompressedSize, len(archive))
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package gozstd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// metaMagic is the skippable frame gozstd writes at the start of archives
+// which need more than a plain zstd decoder. Other decoders skip it.
+const metaMagic = skippableMagic | 0xB
+
+// metaSignature starts the payload of a meta frame, a kind byte follows.
+var metaSignature = []byte("GOZSTD")
+
+const (
+	metaPrime = 'P' // The frames are primed, a uint32 with Options.Prime follows
+	metaDict  = 'D' // The dictionary of Options.SelfDict follows
+)
+
+// appendPrimeFrame appends the meta frame of an archive made with prime.
+func appendPrimeFrame(dst []byte, prime int) []byte {
+	return appendMetaFrame(dst, metaPrime, binary.LittleEndian.AppendUint32(nil, uint32(prime)))
+}
+
+// appendDictFrame appends the meta frame holding the dictionary of SelfDict.
+func appendDictFrame(dst []byte, dict []byte) []byte {
+	return appendMetaFrame(dst, metaDict, dict)
+}
+
+// archiveMeta is what the meta frames at the start of an archive say.
+type archiveMeta struct {
+	prime int
+	dict  []byte
+
+	frames int   // Number of meta frames
+	size   int64 // Their size in bytes
+}
+
+// appendMetaFrame appends a meta frame of the given kind and data to dst.
+func appendMetaFrame(dst []byte, kind byte, data []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, metaMagic)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(metaSignature)+1+len(data)))
+	dst = append(dst, metaSignature...)
+	dst = append(dst, kind)
+	return append(dst, data...)
+}
+
+// readMeta consumes the meta frames at the start of br and returns what they
+// say. Anything else is left in br.
+func readMeta(br *bufio.Reader) (archiveMeta, error) {
+	var meta archiveMeta
+	for {
+		header, err := br.Peek(8 + len(metaSignature))
+		if err != nil || binary.LittleEndian.Uint32(header) != metaMagic || !bytes.Equal(header[8:], metaSignature) {
+			return meta, nil
+		}
+		size := int64(binary.LittleEndian.Uint32(header[4:]))
+		payloadSize := size - int64(len(metaSignature))
+		if payloadSize < 1 {
+			return meta, &FrameError{Frame: meta.frames, Offset: meta.size, Err: errors.New("empty gozstd frame")}
+		}
+		br.Discard(8 + len(metaSignature))
+		// The size comes from the file, only what is there gets allocated.
+		payload, err := io.ReadAll(io.LimitReader(br, payloadSize))
+		if err == nil && int64(len(payload)) < payloadSize {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil && payload[0] == metaPrime {
+			if len(payload) != 5 {
+				err = errors.New("invalid gozstd prime frame")
+			} else {
+				meta.prime = int(binary.LittleEndian.Uint32(payload[1:]))
+			}
+		}
+		if err == nil && payload[0] == metaDict {
+			if len(payload) == 1 {
+				err = errors.New("empty gozstd dictionary frame")
+			} else {
+				meta.dict = payload[1:]
+			}
+		}
+		// Unknown kinds are skipped like any skippable frame.
+		if err != nil {
+			return meta, &FrameError{Frame: meta.frames, Offset: meta.size, Err: err}
+		}
+		meta.frames++
+		meta.size += 8 + size
+	}
+}
+
+// newFrameScanner returns a scanner for the frames after the meta frames,
+// with offsets and frame numbers counted from the start of the archive.
+func (m archiveMeta) newFrameScanner(br *bufio.Reader) *frameScanner {
+	s := newFrameScanner(br)
+	s.offset, s.frames = m.size, m.frames
+	return s
+}
@@ -0,0 +1,85 @@
+package gozstd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReadMeta(t *testing.T) {
+	dict := []byte("some dictionary")
+	archive := appendPrimeFrame(nil, 4096)
+	archive = appendMetaFrame(archive, 'X', []byte("unknown kinds are skipped"))
+	archive = appendDictFrame(archive, dict)
+	size := int64(len(archive))
+	archive = append(archive, "rest"...)
+
+	br := bufio.NewReader(bytes.NewReader(archive))
+	meta, err := readMeta(br)
+	if err != nil {
+		t.Fatalf("readMeta: %v", err)
+	}
+	if meta.prime != 4096 || !bytes.Equal(meta.dict, dict) || meta.frames != 3 || meta.size != size {
+		t.Errorf("got prime %d, dict %q, %d frames of %d bytes", meta.prime, meta.dict, meta.frames, meta.size)
+	}
+	if rest, _ := io.ReadAll(br); string(rest) != "rest" {
+		t.Errorf("left %q in the reader, want the rest of the archive", rest)
+	}
+
+	// A plain archive has no meta frames and nothing is consumed.
+	plain := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock}, testData(1000))
+	br = bufio.NewReader(bytes.NewReader(plain))
+	if meta, err := readMeta(br); err != nil || meta.frames != 0 || meta.size != 0 {
+		t.Errorf("plain archive: got %+v, %v", meta, err)
+	}
+	if rest, _ := io.ReadAll(br); !bytes.Equal(rest, plain) {
+		t.Errorf("plain archive: %d bytes left of %d", len(rest), len(plain))
+	}
+}
+
+func TestReadMetaErrors(t *testing.T) {
+	header := func(size uint32) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, metaMagic)
+		b = binary.LittleEndian.AppendUint32(b, size)
+		return append(b, metaSignature...)
+	}
+	tests := []struct {
+		name    string
+		archive []byte
+		want    error
+	}{
+		{"no kind", header(uint32(len(metaSignature))), nil},
+		{"size below signature", header(2), nil},
+		{"size beyond the file", append(header(1<<32-1), metaPrime, 1, 2), io.ErrUnexpectedEOF},
+		{"short prime", appendMetaFrame(nil, metaPrime, []byte{1, 2}), nil},
+		{"empty dictionary", appendMetaFrame(nil, metaDict, nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMeta(bufio.NewReader(bytes.NewReader(tt.archive)))
+			var frameErr *FrameError
+			if !errors.As(err, &frameErr) {
+				t.Fatalf("got %v, want a FrameError", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// The errors of a bad meta frame reach the caller as corrupt input.
+func TestBadMetaArchive(t *testing.T) {
+	archive := appendMetaFrame(nil, metaPrime, []byte{1})
+	archive = append(archive, compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock}, testData(1000))...)
+	d, _ := NewDecompressor(Options{Threads: 2})
+	if err := d.Decompress(bytes.NewReader(archive), io.Discard); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Decompress: got %v, want ErrCorrupt", err)
+	}
+	if err := d.Test(bytes.NewReader(archive)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Test: got %v, want ErrCorrupt", err)
+	}
+}
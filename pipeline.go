@@ -0,0 +1,103 @@
+package gozstd
+
+import (
+	"io"
+	"sync"
+)
+
+// chunk is one unit of work for runOrdered.
+type chunk struct {
+	index  int
+	offset int64 // Offset of the input in the compressed stream, when decoding
+	in     []byte
+	out    []byte
+	frames []int  // Size of every frame in out, when encoding
+	prefix []byte // End of the previous chunk, with Options.Prime
+	err    error
+	done   chan struct{}
+}
+
+// runOrdered gets chunks from next, processes them with work on numThreads
+// goroutines and passes them to write in the same order next returned them.
+// next returns io.EOF when there is no more input. At most about 2*numThreads
+// chunks are held in memory at any time. The chunks are counted in progress. When work or write fails it waits
+// for the goroutines to stop before it returns, so the caller can close what
+// they use.
+func runOrdered(numThreads int, progress *Progress, next func() (*chunk, error), work func(*chunk), write func(*chunk) error) error {
+	jobs := make(chan *chunk)
+	pending := make(chan *chunk, numThreads)
+	quit := make(chan struct{})
+	var readErr error
+
+	var wg sync.WaitGroup
+	for i := 0; i < numThreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				// After a failure the chunks left are dropped unseen.
+				select {
+				case <-quit:
+				default:
+					work(c)
+				}
+				close(c.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for index := 0; ; index++ {
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			c, err := next()
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+			c.index = index
+			c.done = make(chan struct{})
+			progress.addChunkRead()
+			// The writer has to know about the chunk before a worker picks it
+			// up, otherwise the order could be lost.
+			select {
+			case pending <- c:
+			case <-quit:
+				return
+			}
+			select {
+			case jobs <- c:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for c := range pending {
+		<-c.done
+		err := c.err
+		if err == nil {
+			err = write(c)
+		}
+		if err == nil {
+			progress.addChunkDone()
+		}
+		if err != nil {
+			close(quit)
+			for range pending {
+			}
+			wg.Wait()
+			return err
+		}
+	}
+	wg.Wait()
+
+	return readErr
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gozstd"
+)
+
+// benchResult is one line of the bench table, and one object of -json.
+type benchResult struct {
+	Mode           string  `json:"mode"`
+	Level          int     `json:"level"`
+	Threads        int     `json:"threads"`
+	InputSize      int     `json:"input_size"`
+	CompressedSize int     `json:"compressed_size"`
+	Ratio          float64 `json:"ratio"`
+	CompressMBs    float64 `json:"compress_mb_s"`
+	DecompressMBs  float64 `json:"decompress_mb_s"`
+}
+
+// parseList parses a list like 1,2,4 or a range like 1-19, or both as 1-3,9.
+func parseList(s string) ([]int, error) {
+	var list []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", lo)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(hi); err != nil || to < from {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for i := from; i <= to; i++ {
+			list = append(list, i)
+		}
+	}
+	return list, nil
+}
+
+// runBench is the bench subcommand: it compresses a file in memory with every
+// mode, level and thread count asked for, checks it decompresses to the same
+// bytes and prints the ratio and the speeds.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	levels := fs.String("l", "3", "Levels to test, eg. 1-19 or 1,3,9")
+	threads := fs.String("T", "2", "Thread counts to test, eg. 1,2,4,8")
+	modes := fs.String("mode", "stream,block", "Modes to test: stream, block or auto")
+	minTime := fs.Duration("time", time.Second, "Repeat every compression and decompression for at least this long, the fastest run counts")
+	jsonOut := fs.Bool("json", false, "Print the results as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gozstd bench [options] file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("bench needs one input file")
+	}
+
+	levelList, err := parseList(*levels)
+	if err != nil {
+		return fmt.Errorf("invalid -l: %w", err)
+	}
+	threadList, err := parseList(*threads)
+	if err != nil {
+		return fmt.Errorf("invalid -T: %w", err)
+	}
+	var modeList []gozstd.Mode
+	for _, s := range strings.Split(*modes, ",") {
+		mode, err := parseMode(s)
+		if err != nil {
+			return fmt.Errorf("invalid -mode: %w", err)
+		}
+		modeList = append(modeList, mode)
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	if !*jsonOut {
+		fmt.Printf("%s, %s, %s\n", fs.Arg(0), formatSize(int64(len(data))), gozstd.DefaultBackend().Name())
+		fmt.Printf("%-12s %5s %7s %12s %7s %13s %13s\n", "Mode", "Level", "Threads", "Compressed", "Ratio", "Compress", "Decompress")
+	}
+	var results []benchResult
+	for _, mode := range modeList {
+		for _, level := range levelList {
+			for _, t := range threadList {
+				r, err := benchOne(data, gozstd.Options{Level: level, Threads: t, Mode: mode}, *minTime)
+				if err != nil {
+					return fmt.Errorf("%s mode, level %d, %d threads: %w", mode, level, t, err)
+				}
+				if !*jsonOut {
+					fmt.Printf("%-12s %5d %7d %12d %7.3f %8.1f MB/s %8.1f MB/s\n", r.Mode, r.Level, r.Threads, r.CompressedSize, r.Ratio, r.CompressMBs, r.DecompressMBs)
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	return nil
+}
+
+// benchOne compresses and decompresses data with opts, each repeatedly for
+// at least minTime, and checks the round trip.
+func benchOne(data []byte, opts gozstd.Options, minTime time.Duration) (benchResult, error) {
+	compressor, err := gozstd.NewCompressor(opts)
+	if err != nil {
+		return benchResult{}, err
+	}
+	// Compress cannot see the size of a bytes.Reader, pick the mode here.
+	name := opts.Mode.String()
+	if opts.Mode == gozstd.ModeAuto {
+		opts.Mode, _ = compressor.ChooseMode(int64(len(data)))
+		name += "/" + opts.Mode.String()
+		if compressor, err = gozstd.NewCompressor(opts); err != nil {
+			return benchResult{}, err
+		}
+	}
+	decompressor, err := gozstd.NewDecompressor(opts)
+	if err != nil {
+		return benchResult{}, err
+	}
+
+	var compressed, decompressed bytes.Buffer
+	compressTime, err := fastest(minTime, func() error {
+		compressed.Reset()
+		return compressor.Compress(bytes.NewReader(data), &compressed)
+	})
+	if err != nil {
+		return benchResult{}, fmt.Errorf("compression failed: %w", err)
+	}
+	decompressTime, err := fastest(minTime, func() error {
+		decompressed.Reset()
+		return decompressor.Decompress(bytes.NewReader(compressed.Bytes()), &decompressed)
+	})
+	if err != nil {
+		return benchResult{}, fmt.Errorf("decompression failed: %w", err)
+	}
+	if !bytes.Equal(decompressed.Bytes(), data) {
+		return benchResult{}, fmt.Errorf("round trip failed: the decompressed data differs from the input")
+	}
+
+	r := benchResult{Mode: name, Level: opts.Level, Threads: opts.Threads, InputSize: len(data), CompressedSize: compressed.Len()}
+	if r.CompressedSize > 0 {
+		r.Ratio = float64(len(data)) / float64(r.CompressedSize)
+	}
+	r.CompressMBs = float64(len(data)) / compressTime.Seconds() / 1e6
+	r.DecompressMBs = float64(len(data)) / decompressTime.Seconds() / 1e6
+	return r, nil
+}
+
+// fastest runs fn until minTime has passed, at least once, and returns the
+// time of the fastest run.
+func fastest(minTime time.Duration, fn func() error) (time.Duration, error) {
+	var best time.Duration
+	for start := time.Now(); best == 0 || time.Since(start) < minTime; {
+		t := time.Now()
+		if err := fn(); err != nil {
+			return 0, err
+		}
+		if d := time.Since(t); best == 0 || d < best {
+			best = max(d, time.Nanosecond)
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"gozstd"
+)
+
+// Exit codes of gozstd. Scripts depend on them, so they do not change. When
+// several files fail the highest code is used.
+const (
+	exitOK       = 0
+	exitFailure  = 1 // Anything not listed below
+	exitUsage    = 2 // Invalid flags or options, like the flag package uses
+	exitIO       = 3 // Reading the input or writing the output failed
+	exitCorrupt  = 4 // The input is not valid zstd data
+	exitChecksum = 5 // The content does not match its checksum
+
+	exitSignal = 128 // Plus the signal number as shells report it, 130 for SIGINT and 143 for SIGTERM
+)
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, gozstd.ErrUsage):
+		return exitUsage
+	case errors.Is(err, gozstd.ErrChecksum):
+		return exitChecksum
+	case errors.Is(err, gozstd.ErrCorrupt):
+		return exitCorrupt
+	case errors.Is(err, gozstd.ErrIO), errors.As(err, &pathErr):
+		return exitIO
+	}
+	return exitFailure
+}
+
+// fatalf prints a message on stderr and exits with code.
+func fatalf(code int, format string, args ...any) {
+	warnf(format, args...)
+	os.Exit(code)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"gozstd"
+)
+
+func TestExitCode(t *testing.T) {
+	_, notFound := os.Open("/nonexistent/file")
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("a.zst already exists"), exitFailure},
+		{fmt.Errorf("bad: %w", gozstd.ErrUsage), exitUsage},
+		{notFound, exitIO},
+		{fmt.Errorf("write: %w", gozstd.ErrIO), exitIO},
+		{fmt.Errorf("frame: %w", gozstd.ErrCorrupt), exitCorrupt},
+		{fmt.Errorf("frame: %w", gozstd.ErrChecksum), exitChecksum},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+	// The errors of the library come with their kind.
+	_, err := gozstd.TrainDict(nil, gozstd.DefaultMaxDictSize, 22)
+	if got := exitCode(err); got != exitUsage {
+		t.Errorf("exitCode(%v) = %d, want %d", err, got, exitUsage)
+	}
+}
@@ -1,328 +1,867 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 
-	"github.com/klauspost/compress/zstd"
+	"gozstd"
 )
 
-const oneMB = 1 << 20
-
 var (
 	version   string // Will hold the version number
 	buildTime string // Will hold the build time
 )
 
-func printVersionBuildInfo() {
-	fmt.Printf("Version: %s\nBuild time: %s\n", version, buildTime)
+func printVersionBuildInfo(w io.Writer) {
+	fmt.Fprintf(w, "Version: %s\nBuild time: %s\nBackend: %s\n", version, buildTime, gozstd.DefaultBackend().Name())
 }
 
-func compressStream(input io.Reader, output io.Writer, compressionLevel int) error {
-	encoder, err := zstd.NewWriter(output, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(compressionLevel)))
-	if err != nil {
-		return fmt.Errorf("failed to create zstd encoder: %w", err)
+// longFlag is the value of -long. It can be given alone like a bool flag or
+// with a window log as -long=30.
+type longFlag struct {
+	windowLog int
+}
+
+const defaultLongWindowLog = 27
+
+func (l *longFlag) String() string {
+	if l == nil || l.windowLog == 0 {
+		return ""
 	}
-	defer encoder.Close()
+	return strconv.Itoa(l.windowLog)
+}
 
-	_, err = io.Copy(encoder, input)
-	if err != nil {
-		return fmt.Errorf("failed to compress data: %w", err)
+func (l *longFlag) Set(s string) error {
+	switch s {
+	case "true":
+		l.windowLog = defaultLongWindowLog
+		return nil
+	case "false":
+		l.windowLog = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 10 || n > 29 {
+		return fmt.Errorf("window log must be between 10 and 29")
 	}
+	l.windowLog = n
+	return nil
+}
+
+func (l *longFlag) IsBoolFlag() bool {
+	return true
+}
 
+// adaptFlag is the value of -adapt. Alone it uses the whole level range, or
+// it takes a range as -adapt=3,15 or like zstd as -adapt=min=3,max=15.
+type adaptFlag struct {
+	enabled  bool
+	min, max int
+}
+
+func (a *adaptFlag) String() string {
+	if a == nil || !a.enabled {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d", a.min, a.max)
+}
+
+func (a *adaptFlag) Set(s string) error {
+	*a = adaptFlag{enabled: s != "false"}
+	if s == "true" || s == "false" {
+		return nil
+	}
+	lo, hi, found := strings.Cut(s, ",")
+	if !found {
+		return fmt.Errorf("want min,max")
+	}
+	var err error
+	if a.min, err = strconv.Atoi(strings.TrimPrefix(lo, "min=")); err != nil {
+		return fmt.Errorf("invalid min level %q", lo)
+	}
+	if a.max, err = strconv.Atoi(strings.TrimPrefix(hi, "max=")); err != nil {
+		return fmt.Errorf("invalid max level %q", hi)
+	}
 	return nil
 }
 
-func compressPart(inputFile string, segmentIndex int, offset [2]int64, compressionLevel int) (outputFile string, err1 error) {
-	input, err := os.Open(inputFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to create openfile: %w", err)
+func (a *adaptFlag) IsBoolFlag() bool {
+	return true
+}
+
+// parseMode parses the value of -mode.
+func parseMode(s string) (gozstd.Mode, error) {
+	for _, m := range []gozstd.Mode{gozstd.ModeStream, gozstd.ModeBlock, gozstd.ModeAuto} {
+		if s == m.String() {
+			return m, nil
+		}
 	}
-	defer input.Close()
+	return 0, fmt.Errorf("unknown mode %q, want stream, block or auto", s)
+}
 
-	outputFile = fmt.Sprintf("%d-%s-output-segment.part%d", segmentIndex, filepath.Base(inputFile), segmentIndex)
-	output, err := os.Create(outputFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to create output file: %w", err)
+// parseSize parses a size like 4096, 64K, 1G or 1.5M. Suffixes are powers of 1024.
+func parseSize(arg string) (int64, error) {
+	mult := int64(1)
+	s := strings.TrimSuffix(strings.ToUpper(arg), "B")
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", arg)
 	}
-	defer output.Close()
+	return int64(v * float64(mult)), nil
+}
+
+// parseRange parses offset:length for -range. The length may be left out to
+// read up to the end.
+func parseRange(s string) (offset, length int64, err error) {
+	off, l, found := strings.Cut(s, ":")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid range %q, want offset:length", s)
+	}
+	if offset, err = parseSize(off); err != nil {
+		return 0, 0, err
+	}
+	if l == "" {
+		return offset, -1, nil
+	}
+	length, err = parseSize(l)
+	return offset, length, err
+}
 
-	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(compressionLevel)))
+func decompressRange(decompressor *gozstd.Decompressor, input io.Reader, output io.Writer, rangeArg string) error {
+	offset, length, err := parseRange(rangeArg)
 	if err != nil {
-		return "", fmt.Errorf("failed to create zstd encoder: %w", err)
+		return err
+	}
+	f, ok := input.(*os.File)
+	if !ok || f == os.Stdin {
+		return fmt.Errorf("-range needs an input file")
 	}
-	defer encoder.Close()
+	finfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return decompressor.DecompressRange(f, finfo.Size(), output, offset, length)
+}
 
-	startOffset, endOffset := offset[0], offset[1]
-	buf := make([]byte, oneMB) // 1 MB buffer
-	input.Seek(startOffset, 0)
-	for {
-		n, err := input.Read(buf)
-		if err != nil && err != io.EOF {
-			return "", fmt.Errorf("failed to read input: %w", err)
-		}
-		if n == 0 {
-			break
-		}
+// zstSuffix is added to compressed files, decompressSuffixes maps the known
+// suffixes back to the original name.
+const zstSuffix = ".zst"
 
-		compressed := encoder.EncodeAll(buf[:n], nil)
-		_, err = output.Write(compressed)
-		if err != nil {
-			return "", fmt.Errorf("failed to write output: %w", err)
-		}
-		currentOffset, err := input.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return "", fmt.Errorf("failed to get current offset: %w", err)
-		}
-		if currentOffset == endOffset { // We need to be sure it ends with 1MB boundary or the last one
-			break
+var decompressSuffixes = []struct{ from, to string }{
+	{".zst", ""},
+	{".zstd", ""},
+	{".tzst", ".tar"},
+}
+
+// outputName returns the automatic output name for the input file name, like
+// zstd does: a.zst when compressing a and a when decompressing a.zst.
+func outputName(name string, decompress bool) (string, error) {
+	if !decompress {
+		if strings.HasSuffix(name, zstSuffix) {
+			return "", fmt.Errorf("already has %s suffix -- ignored", zstSuffix)
 		}
-		if currentOffset > endOffset {
-			panic("[ERROR] I read over the endOffset. That means you pass me index not end in 1MB boundary")
+		return name + zstSuffix, nil
+	}
+	for _, s := range decompressSuffixes {
+		if base, found := strings.CutSuffix(name, s.from); found && base != "" {
+			return base + s.to, nil
 		}
 	}
-
-	return outputFile, nil
+	return "", fmt.Errorf("unknown suffix -- ignored")
 }
 
-func divmod(numerator, denominator int64) (quotient, remainder int64) {
-	quotient = numerator / denominator // integer division, decimals are truncated
-	remainder = numerator % denominator
-	return
+// fileJob holds what is needed to compress or decompress one input file.
+type fileJob struct {
+	compressor   *gozstd.Compressor
+	decompressor *gozstd.Decompressor
+	decompress   bool
+	stdout       bool   // -c, write everything to stdout
+	output       string // -o, only allowed with a single input
+	force        bool   // -f, overwrite existing output files
+	remove       bool   // --rm, remove the input once it is done
+	rangeArg     string
+	explain      bool // -explain, print why stream or block mode is used
+	fsync        bool // -fsync, flush the output to disk before it gets its name
 }
 
-func divideFileIntoSegments(fileSize int64, threadCount int) [][2]int64 {
-	var segments [][2]int64
+// jobError is an error of the compressor or decompressor, not of the checks
+// before they run, see runFiles.
+type jobError struct {
+	err error
+}
 
-	// Convert file size to MB boundaries
-	fileSizeMB := (fileSize + oneMB - 1) / oneMB // Round up to the nearest MB
+func (e jobError) Error() string {
+	return e.err.Error()
+}
 
-	// Calculate the size of each segment in MB
-	segmentSizeMB := fileSizeMB / int64(threadCount)
-	remainingMB := fileSizeMB % int64(threadCount)
+func (e jobError) Unwrap() error {
+	return e.err
+}
 
-	// Calculate the start and end offsets for each segment
-	var start int64
-	for i := 0; i < threadCount; i++ {
-		end := start + segmentSizeMB*oneMB
-		if remainingMB > 0 {
-			end += oneMB
-			remainingMB--
+// run compresses or decompresses the file name. Stdin is read when name is
+// "-" and the output then goes to stdout unless -o is given. New and regular
+// output files are written under a temporary name and only renamed once
+// complete, see outputPath.
+func (j *fileJob) run(name string) error {
+	stdin := name == "-"
+	toStdout := j.stdout || (stdin && j.output == "")
+
+	outName := j.output
+	if !toStdout && outName == "" {
+		var err error
+		if outName, err = outputName(name, j.decompress); err != nil {
+			return err
 		}
-		if end > fileSize {
-			end = fileSize
+	}
+	var finfo os.FileInfo
+	if !stdin {
+		var err error
+		if finfo, err = os.Stat(name); err != nil {
+			return err
+		}
+		if finfo.IsDir() {
+			return fmt.Errorf("is a directory -- ignored")
+		}
+	}
+	if !toStdout && !j.force {
+		if _, err := os.Stat(outName); err == nil {
+			return fmt.Errorf("%s already exists; use -f to overwrite", outName)
 		}
-		segments = append(segments, [2]int64{start, end})
-		start = end
 	}
 
-	return segments
+	if toStdout {
+		if err := j.process(name, stdin, true, ""); err != nil {
+			return jobError{err}
+		}
+		return nil
+	}
+
+	path, atomic := outputPath(outName)
+	target := path
+	if atomic {
+		var err error
+		if target, err = createTemp(path); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+	}
+	if err := j.process(name, stdin, false, target); err != nil {
+		if atomic {
+			removeTemp(target)
+		}
+		return jobError{err}
+	}
+	// Only the file gozstd created gets the metadata, never a device or pipe.
+	if !stdin && atomic {
+		if err := copyMetadata(name, finfo, target); err != nil {
+			warnf("%s: warning: %v\n", outName, err)
+		}
+	}
+	if atomic {
+		if err := commitTemp(target, path, j.fsync); err != nil {
+			removeTemp(target)
+			return err
+		}
+	}
+	if j.remove && !stdin {
+		if err := os.Remove(name); err != nil {
+			return fmt.Errorf("failed to remove input file: %w", err)
+		}
+	}
+	return nil
 }
 
-func calculateSegment(inputFile string, numThreads int) (offset [][2]int64, err1 error) {
-	finfo, err := os.Stat(inputFile)
+func (j *fileJob) process(name string, stdin, toStdout bool, outName string) error {
+	var mode gozstd.Mode
+	if !j.decompress {
+		var why string
+		mode, why = j.compressor.ChooseMode(fileSize(name))
+		if j.explain {
+			warnf("%s: %s mode, %s\n", displayName(name), mode, why)
+		}
+	}
+	// CompressFile opens the files itself, it only needs the names.
+	if !j.decompress && !stdin && !toStdout {
+		if mode == gozstd.ModeBlock && j.compressor.Options().Threads > 1 && display == nil {
+			warnf("%s: working, please wait ...\n", name)
+		}
+		return j.compressor.CompressFile(name, outName)
+	}
+
+	var input io.Reader = os.Stdin
+	if !stdin {
+		inputFile, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer inputFile.Close()
+		input = inputFile
+	}
+	if toStdout {
+		return j.convert(input, os.Stdout)
+	}
+	outFile, err := os.Create(outName)
 	if err != nil {
-		return [][2]int64{}, err
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := j.convert(input, outFile); err != nil {
+		outFile.Close()
+		return err
+	}
+	// A failed close can lose the end of the output, it must not be
+	// committed.
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
 	}
-	fSize := finfo.Size()
-	return divideFileIntoSegments(fSize, numThreads), nil
+	return nil
 }
 
-// FileWithIndex represents a file with its numeric index extracted from its name.
-type FileWithIndex struct {
-	Index int
-	Name  string
+// convert compresses or decompresses input into output.
+func (j *fileJob) convert(input io.Reader, output io.Writer) error {
+	switch {
+	case !j.decompress:
+		return j.compressor.Compress(input, output)
+	case j.rangeArg != "":
+		return decompressRange(j.decompressor, input, output, j.rangeArg)
+	}
+	return j.decompressor.Decompress(input, output)
 }
 
-// concatenateFiles concatenates files based on their numeric index and writes them to the output file.
-func concatenateFiles(filenames []string, outputFile string) error {
-	var filesWithIndex []FileWithIndex
+// withOptions returns a copy of j with an encoder or a decoder made with opts.
+func (j fileJob) withOptions(opts gozstd.Options) (*fileJob, error) {
+	var err error
+	if j.decompress {
+		j.decompressor, err = gozstd.NewDecompressor(opts)
+	} else {
+		j.compressor, err = gozstd.NewCompressor(opts)
+	}
+	return &j, err
+}
+
+// largeFileSize is the size from which a file gets all the threads for itself
+// when there are several inputs.
+const largeFileSize = 64 << 20
+
+// isLargeFile reports whether name is processed with the large job.
+func isLargeFile(name string) bool {
+	finfo, err := os.Stat(name)
+	return err == nil && finfo.Size() >= largeFileSize
+}
 
-	// Extract the numeric index from each filename and store it in the filesWithIndex slice.
-	for _, filename := range filenames {
-		base := filepath.Base(filename)
-		parts := strings.SplitN(base, "-", 2)
-		if len(parts) < 2 {
-			return fmt.Errorf("invalid filename pattern: %s", filename)
+// runFiles processes names with the large job one after another, then the
+// rest with the small job, threads files at a time. With -c every file is
+// processed in order with the job for its size. Errors are printed, the ones
+// of the compressor with the mode it used, it returns the exit code.
+func runFiles(large, small *fileJob, names []string, threads int) int {
+	var mu sync.Mutex
+	code := exitOK
+	report := func(job *fileJob, name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		code = max(code, exitCode(err))
+		var jobErr jobError
+		switch {
+		case !errors.As(err, &jobErr):
+			warnf("%s: %v\n", displayName(name), err)
+		case job.decompress:
+			warnf("%s: decompression failed: %v\n", displayName(name), err)
+		default:
+			mode, _ := job.compressor.ChooseMode(fileSize(name))
+			warnf("%s: %s mode compression failed: %v\n", displayName(name), mode, err)
 		}
+	}
 
-		index, err := strconv.Atoi(parts[0])
-		if err != nil {
-			return fmt.Errorf("invalid index in filename: %s", filename)
+	if small == large || small.stdout {
+		for _, name := range names {
+			job := small
+			if isLargeFile(name) {
+				job = large
+			}
+			if err := job.run(name); err != nil {
+				report(job, name, err)
+			}
+		}
+		return code
+	}
+
+	var rest []string
+	for _, name := range names {
+		if isLargeFile(name) {
+			if err := large.run(name); err != nil {
+				report(large, name, err)
+			}
+			continue
 		}
+		rest = append(rest, name)
+	}
 
-		filesWithIndex = append(filesWithIndex, FileWithIndex{Index: index, Name: filename})
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				if err := small.run(name); err != nil {
+					report(small, name, err)
+				}
+			}
+		}()
 	}
+	for _, name := range rest {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+	return code
+}
 
-	// Sort files based on their numeric index.
-	sort.Slice(filesWithIndex, func(i, j int) bool {
-		return filesWithIndex[i].Index < filesWithIndex[j].Index
-	})
+// globsFlag is a glob flag which can be repeated. The globs are checked when
+// they are set.
+type globsFlag []string
 
-	// Create or truncate the output file.
-	out, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+func (g *globsFlag) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globsFlag) Set(s string) error {
+	if _, err := filepath.Match(s, ""); err != nil {
+		return fmt.Errorf("invalid glob %q", s)
 	}
-	defer out.Close()
+	*g = append(*g, s)
+	return nil
+}
 
-	// Concatenate the contents of each file in order.
-	for _, file := range filesWithIndex {
-		in, err := os.Open(file.Name)
-		if err != nil {
-			return fmt.Errorf("failed to open input file %s: %v", file.Name, err)
+// fileFilter selects the files found in directories with -r. Globs without a
+// slash match the file name, the others match the whole path.
+type fileFilter struct {
+	include    []string
+	exclude    []string
+	decompress bool
+}
+
+func (f *fileFilter) match(path string) bool {
+	if _, err := outputName(path, f.decompress); err != nil {
+		// .zst files when compressing, unknown suffixes when decompressing.
+		return false
+	}
+	if len(f.include) > 0 && !matchGlobs(f.include, path) {
+		return false
+	}
+	return !matchGlobs(f.exclude, path)
+}
+
+func matchGlobs(globs []string, path string) bool {
+	for _, g := range globs {
+		name := path
+		if !strings.Contains(g, "/") {
+			name = filepath.Base(path)
+		}
+		if ok, _ := filepath.Match(filepath.FromSlash(g), name); ok {
+			return true
 		}
+	}
+	return false
+}
 
-		_, err = io.Copy(out, in)
-		in.Close()
+// collectFiles expands the directories in args with -r. Files named on the
+// command line are always kept, the ones found in directories go through
+// filter.
+func collectFiles(args []string, recursive bool, filter *fileFilter) ([]string, error) {
+	if !recursive {
+		return args, nil
+	}
+	var files []string
+	for _, arg := range args {
+		finfo, err := os.Stat(arg)
+		if arg == "-" || err != nil || !finfo.IsDir() {
+			// Errors are reported when the file is processed.
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && filter.match(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
 		if err != nil {
-			return fmt.Errorf("failed to write to output file: %v", err)
+			return nil, err
 		}
 	}
-	for _, file := range filesWithIndex {
-		os.Remove(file.Name)
+	return files, nil
+}
+
+// displayName is the name of an input in messages.
+func displayName(name string) string {
+	if name == "-" {
+		return "stdin"
 	}
-	return nil
+	return name
 }
 
-func compressFileBlock(inputFile, outputFile string, compressionLevel, numThreads int) error {
-	offset, err := calculateSegment(inputFile, numThreads)
+// testFile checks the archive name, "-" is stdin.
+func testFile(decompressor *gozstd.Decompressor, name string) error {
+	if name == "-" {
+		return decompressor.Test(os.Stdin)
+	}
+	f, err := os.Open(name)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
+	return decompressor.Test(f)
+}
 
-	var wg sync.WaitGroup
-	outputFileName := make(chan string, numThreads)
-	errChan := make(chan error, numThreads)
+// formatSize prints n in KiB, MiB or GiB like zstd -l does.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
 
-	for i := 0; i < numThreads; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			outfile, err := compressPart(inputFile, i, offset[i], compressionLevel)
+// listFiles prints a line per file with the summary of its frames. With
+// verbose every frame is printed too. Stdin is used when names is empty.
+func listFiles(names []string, verbose bool) error {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprintln(w, "Frames\tSkips\tCompressed\tUncompressed\tRatio\tWindow\tDict\tCheck\tSeek\tFilename\t")
+	for _, name := range names {
+		var input io.Reader = os.Stdin
+		if name != "-" {
+			f, err := os.Open(name)
 			if err != nil {
-				errChan <- err
-				outputFileName <- ""
-				return
+				return err
 			}
-			outputFileName <- outfile
-		}(i)
-	}
+			defer f.Close()
+			input = f
+		}
 
-	go func() {
-		wg.Wait()
-		close(outputFileName)
-		close(errChan)
-	}()
+		var frames []gozstd.FrameInfo
+		var collect func(gozstd.FrameInfo)
+		if verbose {
+			collect = func(info gozstd.FrameInfo) {
+				frames = append(frames, info)
+			}
+		}
+		archive, err := gozstd.List(input, collect)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
 
-	fmt.Fprintln(os.Stderr, "Working, please wait ...")
-	outputFiles := []string{}
-	for fn := range outputFileName {
-		outputFiles = append(outputFiles, fn)
-	}
+		uncompressed, ratio := "-", "-"
+		if archive.ContentSizeKnown {
+			uncompressed = formatSize(archive.DecompressedSize)
+			ratio = fmt.Sprintf("%.3f", archive.Ratio())
+		}
+		dict := "-"
+		if len(archive.DictIDs) > 0 {
+			dict = fmt.Sprint(archive.DictIDs[0])
+			if len(archive.DictIDs) > 1 {
+				dict = "mixed"
+			}
+		}
+		check := "None"
+		if archive.HasChecksum {
+			check = "XXH64"
+		}
+		seek := "No"
+		if archive.SeekTable {
+			seek = "Yes"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", archive.Frames, archive.SkippableFrames,
+			formatSize(archive.CompressedSize), uncompressed, ratio, formatSize(int64(archive.MaxWindowSize)), dict, check, seek, name)
 
-	for err := range errChan {
-		if err != nil {
-			fmt.Println(err.Error())
+		if len(frames) > 0 {
+			w.Flush()
+		}
+		for i, info := range frames {
+			kind := "zstd"
+			if info.SeekTable {
+				kind = "seek-table"
+			} else if info.Skippable {
+				kind = "skippable"
+			}
+			content := "-"
+			if info.ContentSize >= 0 {
+				content = fmt.Sprint(info.ContentSize)
+			}
+			fmt.Printf("  #%-6d %-10s offset=%d compressed=%d uncompressed=%s window=%d dict=%d checksum=%v\n",
+				i, kind, info.Offset, info.Size, content, info.WindowSize, info.DictID, info.HasChecksum)
 		}
-		panic("[ERROR] some errors see above")
 	}
-
-	concatenateFiles(outputFiles, outputFile)
 	return nil
 }
 
-func decompressFile(input io.Reader, output io.Writer) error {
-	decoder, err := zstd.NewReader(input)
+// trainDict reads every sample file and writes the trained dictionary to
+// outputFile, or stdout if it is empty. Like the compressed files it is
+// written under a temporary name first, see outputPath.
+func trainDict(samples []string, outputFile string, maxSize, level int, fsync bool) error {
+	var data [][]byte
+	for _, name := range samples {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if len(b) > 0 {
+			data = append(data, b)
+		}
+	}
+	dict, err := gozstd.TrainDict(data, maxSize, level)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd decoder: %w", err)
+		return err
 	}
-	defer decoder.Close()
-
-	_, err = io.Copy(output, decoder)
+	if outputFile == "" {
+		_, err = os.Stdout.Write(dict)
+		return err
+	}
+	path, atomic := outputPath(outputFile)
+	if !atomic {
+		return os.WriteFile(path, dict, 0o644)
+	}
+	target, err := createTemp(path)
 	if err != nil {
-		return fmt.Errorf("failed to decompress data: %w", err)
+		return err
+	}
+	if err := os.WriteFile(target, dict, 0o644); err != nil {
+		removeTemp(target)
+		return err
+	}
+	if err := commitTemp(target, path, fsync); err != nil {
+		removeTemp(target)
+		return err
 	}
-
 	return nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:]); err != nil {
+			fatalf(exitCode(err), "Benchmark failed: %v\n", err)
+		}
+		return
+	}
+
 	// Define flags
 	compressMode := flag.Bool("d", false, "Decompress instead of compress")
 	outputToStdout := flag.Bool("c", false, "Write output to stdout")
-	outputFile := flag.String("o", "", "Output file (default: stdout)")
+	outputFile := flag.String("o", "", "Output file, only with a single input (default: the input name with .zst added or removed, stdout when reading stdin)")
+	keepSource := flag.Bool("k", false, "Keep the input files (default)")
+	removeSource := flag.Bool("rm", false, "Remove the input files once they are compressed or decompressed")
+	force := flag.Bool("f", false, "Overwrite existing output files")
+	fsync := flag.Bool("fsync", false, "Flush every output file to disk before it gets its final name, so it survives a crash or power loss")
+	progress := flag.Bool("progress", false, "Show bytes read and written, ratio, speed and ETA on stderr. Only when stderr is a terminal")
+	recursive := flag.Bool("r", false, "Compress or decompress the files in the directories given as arguments and their subdirectories")
+	var include, exclude globsFlag
+	flag.Var(&include, "include", "With -r, only process files matching the glob, eg. '*.log'. Can be repeated")
+	flag.Var(&exclude, "exclude", "With -r, skip files matching the glob. Can be repeated")
 	compressionLevel := flag.Int("l", 3, "Set compression level (1-19, default: 3)")
-	numThreads := flag.Int("T", 2, "Number of threads for compression (default: 2)")
-	blockMode := flag.Bool("b", false, "Use block mode for compression. This will use the option -T to utilize more than 2 CPU core. Only benefit if you use compression level higher than 9 otherwise is is not faster in my test but your chances might be vary. You can not use stdin and stdout for this case")
+	ultra := flag.Bool("ultra", false, "Allow compression levels 20-22. Needs a build with -tags cgo_zstd")
+	numThreads := flag.Int("T", 2, "Number of threads for compression and decompression of block mode files (default: 2). With several input files, the files under 64MB are done this many at a time")
+	modeFlag := flag.String("mode", "auto", "Compression mode: stream, block or auto. Auto uses block mode from level 10 when there are several threads and the input is big enough, see -explain")
+	blockMode := flag.Bool("b", false, "Use block mode for compression, same as -mode=block. This will use the option -T to utilize more than 2 CPU core. Only benefit if you use compression level higher than 9 otherwise is is not faster in my test but your chances might be vary. With stdin or stdout the input is compressed in 1MB chunks on the fly")
+	explain := flag.Bool("explain", false, "Print why stream or block mode is used for every input")
+	chunkSize := flag.String("chunk-size", "", "Block mode: the input a thread takes at a time, eg. 16M. It is still compressed into frames of -frame-size (default: one frame)")
+	frameSize := flag.String("frame-size", "", "Block mode: the input in one frame, eg. 16M. Bigger frames compress better, with -chunk-size as big every chunk is one frame (default: 1MB)")
+	prime := flag.String("prime", "", "Block mode: give every frame up to this much of the input before it as history, eg. 1M. Better ratio, but the archive is decompressed serially and needs gozstd (max: the window, 8M by default)")
+	selfDict := flag.Bool("self-dict", false, "Block mode: train a dictionary on samples of the input, compress every frame with it and store it in the archive. Helps small frames of similar records like logs or CSV, the archive needs gozstd to decompress")
+	seekable := flag.Bool("seekable", false, "Block mode only. Append a seek table in the zstd seekable format so readers can jump to any offset")
+	seekChecksums := flag.Bool("seek-checksums", false, "Store the checksum of every frame in the seek table (with -seekable)")
+	listMode := flag.Bool("list", false, "List the frames of the input files: sizes, ratio, window, dictionary, checksum and seek table")
+	verbose := flag.Bool("v", false, "With -list, print every frame")
+	var long longFlag
+	flag.Var(&long, "long", "Long distance matching: use a window of 2^windowLog bytes, -long alone means -long=27. In block mode the frames get as big as the window")
+	var adapt adaptFlag
+	flag.Var(&adapt, "adapt", "Change the level between frames so the compression keeps up with a slow or fast output, like a network pipe. -adapt alone uses levels 1-19, -adapt=min,max a range. Turns on block mode")
+	window := flag.String("window", "", "Encoder window size, a power of 2 like 64M. Same as -long but in bytes")
+	memory := flag.String("memory", "", "With -d or -t, the largest window the decoder accepts, eg. 1G (default: 512M)")
+	dictFile := flag.String("D", "", "Use the dictionary file for compression and decompression")
+	trainMode := flag.Bool("train", false, "Train a dictionary from the sample files given as arguments and write it to -o")
+	maxDict := flag.Int("maxdict", gozstd.DefaultMaxDictSize, "Maximum dictionary size for -train")
+	testMode := flag.Bool("t", false, "Test the integrity of the compressed input without writing any output")
+	rangeFlag := flag.String("range", "", "With -d, only decompress the bytes offset:length of the content, eg. 1G:64M. Needs an input file")
 	// With -l 15 the block mode is around three times faster than stream mode with -T 4. However if -l 9 then it is slightly slower (0.3sec)
 	// So for low level compression <=9 use stream.
 
 	flag.Usage = func() {
-		printVersionBuildInfo()
+		printVersionBuildInfo(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: gozstd [options] [files]\n       gozstd bench [options] file, see gozstd bench -h")
 		flag.PrintDefaults()
 	}
 	// Parse flags
 	flag.Parse()
 
-	// Determine input source
-	var input io.Reader = os.Stdin
-	if flag.NArg() > 0 {
-		inputFile, err := os.Open(flag.Arg(0))
-		if err != nil {
-			fmt.Printf("Failed to open input file: %v\n", err)
-			os.Exit(1)
+	if *trainMode {
+		handleSignals()
+		if err := trainDict(flag.Args(), *outputFile, *maxDict, *compressionLevel, *fsync); err != nil {
+			fatalf(exitCode(err), "Training failed: %v\n", err)
 		}
-		defer inputFile.Close()
-		input = inputFile
+		return
 	}
 
-	// Determine output destination
-	var output io.Writer = os.Stdout
-	if !*outputToStdout {
-		if *outputFile != "" {
-			outFile, err := os.Create(*outputFile)
-			if err != nil {
-				fmt.Printf("Failed to create output file: %v\n", err)
-				os.Exit(1)
-			}
-			defer outFile.Close()
-			output = outFile
+	if *listMode {
+		if err := listFiles(flag.Args(), *verbose); err != nil {
+			fatalf(exitCode(err), "List failed: %v\n", err)
 		}
+		return
+	}
+
+	mode, err := parseMode(*modeFlag)
+	if err != nil {
+		fatalf(exitUsage, "Invalid -mode: %v\n", err)
+	}
+	opts := gozstd.Options{Level: *compressionLevel, Threads: *numThreads, Mode: mode, Seekable: *seekable, SeekChecksums: *seekChecksums, SelfDict: *selfDict, Ultra: *ultra}
+	if long.windowLog != 0 {
+		opts.WindowSize = 1 << long.windowLog
+	}
+	if *window != "" {
+		size, err := parseSize(*window)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -window: %v\n", err)
+		}
+		opts.WindowSize = int(size)
+	}
+	if *chunkSize != "" {
+		size, err := parseSize(*chunkSize)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -chunk-size: %v\n", err)
+		}
+		opts.ChunkSize = int(size)
+	}
+	if *frameSize != "" {
+		size, err := parseSize(*frameSize)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -frame-size: %v\n", err)
+		}
+		opts.FrameSize = int(size)
+	}
+	if *prime != "" {
+		size, err := parseSize(*prime)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -prime: %v\n", err)
+		}
+		opts.Prime = int(size)
+	}
+	if *memory != "" {
+		size, err := parseSize(*memory)
+		if err != nil {
+			fatalf(exitUsage, "Invalid -memory: %v\n", err)
+		}
+		opts.MaxMemory = uint64(size)
+	}
+	if *dictFile != "" {
+		dict, err := os.ReadFile(*dictFile)
+		if err != nil {
+			fatalf(exitIO, "Failed to read dictionary: %v\n", err)
+		}
+		opts.Dict = dict
+	}
+	if *blockMode {
+		opts.Mode = gozstd.ModeBlock
+	}
+	if adapt.enabled {
+		opts.Adapt, opts.AdaptMin, opts.AdaptMax = true, adapt.min, adapt.max
+	}
+
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	filter := &fileFilter{include: include, exclude: exclude, decompress: *compressMode || *testMode}
+	inputs, err = collectFiles(inputs, *recursive, filter)
+	if err != nil {
+		fatalf(exitCode(err), "Failed to read input files: %v\n", err)
+	}
+	if len(inputs) > 1 && *outputFile != "" && !*outputToStdout {
+		fatalf(exitUsage, "-o can only be used with a single input file\n")
+	}
+	if *rangeFlag != "" && (!*compressMode || *testMode) {
+		fatalf(exitUsage, "-range can only be used with -d\n")
+	}
+	// The archive holds more than the range, it must not be removed.
+	if *rangeFlag != "" && *removeSource && !*keepSource {
+		fatalf(exitUsage, "-rm cannot be used with -range\n")
+	}
+
+	if *progress && stderrIsTerminal() {
+		opts.Progress = &gozstd.Progress{}
 	}
 
 	// Handle compression/decompression
-	if *compressMode {
-		err := decompressFile(input, output)
+	if *testMode {
+		decompressor, err := gozstd.NewDecompressor(opts)
 		if err != nil {
-			fmt.Printf("Decompression failed: %v\n", err)
-			os.Exit(1)
+			fatalf(exitCode(err), "Test failed: %v\n", err)
 		}
-	} else {
-		if *blockMode {
-			if flag.NArg() < 0 || *outputFile == "" {
-				panic("[ERROR] Block mode does not support non seekable stream like stdin or stdout. Require option inputfile and -o <outputfile> to work")
+		display = startProgress(opts.Progress, inputSize(inputs), true)
+		code := exitOK
+		for _, name := range inputs {
+			if err := testFile(decompressor, name); err != nil {
+				warnf("%s: test failed: %v\n", displayName(name), err)
+				code = max(code, exitCode(err))
+				continue
 			}
-			inputFile := flag.Arg(0)
+			warnf("%s: OK\n", displayName(name))
+		}
+		display.stop()
+		os.Exit(code)
+	}
 
-			err := compressFileBlock(inputFile, *outputFile, *compressionLevel, *numThreads)
-			if err != nil {
-				fmt.Printf("Block mode compression failed: %v\n", err)
-				os.Exit(1)
-			}
-		} else {
-			err := compressStream(input, output, *compressionLevel)
-			if err != nil {
-				fmt.Printf("Stream mode compression failed: %v\n", err)
-				os.Exit(1)
-			}
+	job := fileJob{decompress: *compressMode, stdout: *outputToStdout, force: *force, remove: *removeSource && !*keepSource, rangeArg: *rangeFlag, explain: *explain, fsync: *fsync}
+	if !*outputToStdout {
+		job.output = *outputFile
+	}
+	large, err := job.withOptions(opts)
+	if err != nil {
+		fatalf(exitCode(err), "Failed to create the encoder or decoder: %v\n", err)
+	}
+	small := large
+	// Large files get all the threads in block mode, unless stream mode is
+	// asked for. Small files run -T at a time with one thread each, with -c
+	// one after another as the order matters.
+	if len(inputs) > 1 && !*compressMode && opts.Mode == gozstd.ModeAuto && opts.Threads > 1 {
+		largeOpts := opts
+		largeOpts.Mode = gozstd.ModeBlock
+		if large, err = job.withOptions(largeOpts); err != nil {
+			fatalf(exitCode(err), "Failed to create the encoder or decoder: %v\n", err)
+		}
+	}
+	if len(inputs) > 1 && !*outputToStdout && opts.Threads > 1 {
+		smallOpts := opts
+		smallOpts.Threads = 1
+		if small, err = job.withOptions(smallOpts); err != nil {
+			fatalf(exitCode(err), "Failed to create the encoder or decoder: %v\n", err)
 		}
 	}
+	handleSignals()
+	display = startProgress(opts.Progress, inputSize(inputs), *compressMode)
+	code := runFiles(large, small, inputs, opts.Threads)
+	display.stop()
+	os.Exit(code)
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gozstd"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want int64
+	}{
+		{"4096", 4096},
+		{"64K", 64 << 10},
+		{"64kb", 64 << 10},
+		{"1.5M", 3 << 19},
+		{"1G", 1 << 30},
+		{"2T", 2 << 40},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.arg)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.arg, got, err, tt.want)
+		}
+	}
+	for _, arg := range []string{"", "K", "-1", "12X", "1..5M"} {
+		if _, err := parseSize(arg); err == nil {
+			t.Errorf("parseSize(%q) did not fail", arg)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		arg            string
+		offset, length int64
+	}{
+		{"0:10", 0, 10},
+		{"1G:64M", 1 << 30, 64 << 20},
+		{"100:", 100, -1},
+	}
+	for _, tt := range tests {
+		offset, length, err := parseRange(tt.arg)
+		if err != nil || offset != tt.offset || length != tt.length {
+			t.Errorf("parseRange(%q) = %d, %d, %v, want %d, %d", tt.arg, offset, length, err, tt.offset, tt.length)
+		}
+	}
+	for _, arg := range []string{"", "10", ":10", "a:b", "10:-1"} {
+		if _, _, err := parseRange(arg); err == nil {
+			t.Errorf("parseRange(%q) did not fail", arg)
+		}
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		name       string
+		decompress bool
+		want       string
+	}{
+		{"a.log", false, "a.log.zst"},
+		{"dir/a", false, "dir/a.zst"},
+		{"a.log.zst", true, "a.log"},
+		{"a.zstd", true, "a"},
+		{"a.tzst", true, "a.tar"},
+	}
+	for _, tt := range tests {
+		got, err := outputName(tt.name, tt.decompress)
+		if err != nil || got != tt.want {
+			t.Errorf("outputName(%q, %v) = %q, %v, want %q", tt.name, tt.decompress, got, err, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		name       string
+		decompress bool
+	}{{"a.zst", false}, {"a.gz", true}, {".zst", true}} {
+		if got, err := outputName(tt.name, tt.decompress); err == nil {
+			t.Errorf("outputName(%q, %v) = %q, want an error", tt.name, tt.decompress, got)
+		}
+	}
+}
+
+// newTestJob returns a job with the default options.
+func newTestJob(t *testing.T, job fileJob) *fileJob {
+	t.Helper()
+	j, err := job.withOptions(gozstd.DefaultOptions())
+	if err != nil {
+		t.Fatalf("withOptions: %v", err)
+	}
+	return j
+}
+
+func TestFileJobRun(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.log")
+	data := bytes.Repeat([]byte("gozstd file job\n"), 10000)
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	compress := newTestJob(t, fileJob{})
+	if err := compress.run(name); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	// An existing output is refused before anything is compressed.
+	err := compress.run(name)
+	var jobErr jobError
+	if err == nil || errors.As(err, &jobErr) {
+		t.Fatalf("existing output: got %v, want an error of the checks", err)
+	}
+	if err := newTestJob(t, fileJob{force: true}).run(name); err != nil {
+		t.Fatalf("compress with -f: %v", err)
+	}
+
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := newTestJob(t, fileJob{decompress: true, remove: true}).run(name + ".zst"); err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("decompressed %d bytes, want %d: %v", len(got), len(data), err)
+	}
+	if _, err := os.Stat(name + ".zst"); !os.IsNotExist(err) {
+		t.Errorf("the archive was not removed: %v", err)
+	}
+
+	// A failure of the decompressor is a jobError and leaves nothing behind.
+	bad := filepath.Join(dir, "bad.zst")
+	if err := os.WriteFile(bad, []byte("not zstd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newTestJob(t, fileJob{decompress: true, remove: true}).run(bad); !errors.As(err, &jobErr) || exitCode(err) != exitCorrupt {
+		t.Fatalf("corrupt archive: got %v, want a corrupt jobError", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("%d files left, want a.log and bad.zst", len(entries))
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.txt", "c.log.zst", "sub/d.log", "sub/e.log.zst", "skip/f.log"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		filter fileFilter
+		want   []string
+	}{
+		{fileFilter{}, []string{"a.log", "b.txt", "skip/f.log", "sub/d.log"}},
+		{fileFilter{decompress: true}, []string{"c.log.zst", "sub/e.log.zst"}},
+		{fileFilter{include: []string{"*.log"}}, []string{"a.log", "skip/f.log", "sub/d.log"}},
+		// A glob with a slash matches the whole path.
+		{fileFilter{include: []string{"*.log"}, exclude: []string{filepath.ToSlash(dir) + "/skip/*"}}, []string{"a.log", "sub/d.log"}},
+		{fileFilter{exclude: []string{"*.txt", "d.*"}}, []string{"a.log", "skip/f.log"}},
+	}
+	for _, tt := range tests {
+		files, err := collectFiles([]string{dir}, true, &tt.filter)
+		if err != nil {
+			t.Fatalf("collectFiles: %v", err)
+		}
+		var got []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	// Files named on the command line are kept as they are.
+	args := []string{filepath.Join(dir, "c.log.zst"), "-", filepath.Join(dir, "missing")}
+	files, err := collectFiles(args, true, &fileFilter{})
+	if err != nil || strings.Join(files, " ") != strings.Join(args, " ") {
+		t.Errorf("got %v, %v, want %v", files, err, args)
+	}
+}
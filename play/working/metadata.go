@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// copyMetadata gives dst the extended attributes, owner, permissions and
+// times of src like zstd and gzip do. The owner is only copied when running
+// as root. finfo must come from before src was read, reading changes the
+// access time. All the steps are tried, the errors are joined.
+func copyMetadata(src string, finfo os.FileInfo, dst string) error {
+	var errs []error
+	if err := copyXattrs(src, dst); err != nil {
+		errs = append(errs, fmt.Errorf("failed to copy extended attributes: %w", err))
+	}
+	if err := copyOwner(finfo, dst); err != nil {
+		errs = append(errs, fmt.Errorf("failed to copy owner: %w", err))
+	}
+	// After chown, which clears the setuid and setgid bits.
+	if err := os.Chmod(dst, finfo.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to copy permissions: %w", err))
+	}
+	if err := os.Chtimes(dst, accessTime(finfo), finfo.ModTime()); err != nil {
+		errs = append(errs, fmt.Errorf("failed to copy times: %w", err))
+	}
+	return errors.Join(errs...)
+}
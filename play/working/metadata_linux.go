@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+func accessTime(finfo os.FileInfo) time.Time {
+	if st, ok := finfo.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	}
+	return finfo.ModTime()
+}
+
+func copyOwner(finfo os.FileInfo, dst string) error {
+	st, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Chown(dst, int(st.Uid), int(st.Gid))
+}
+
+// copyXattrs copies the extended attributes. A file system without them and
+// attributes a normal user may not set, like security.*, are not errors.
+func copyXattrs(src, dst string) error {
+	names, err := xattrGet(func(buf []byte) (int, error) {
+		return syscall.Listxattr(src, buf)
+	})
+	if err != nil || len(names) == 0 {
+		return ignoreXattrErr(err)
+	}
+	for _, name := range bytes.Split(bytes.TrimSuffix(names, []byte{0}), []byte{0}) {
+		value, err := xattrGet(func(buf []byte) (int, error) {
+			return syscall.Getxattr(src, string(name), buf)
+		})
+		if err == nil {
+			err = syscall.Setxattr(dst, string(name), value, 0)
+		}
+		if err = ignoreXattrErr(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xattrGet calls get once for the size and again with a buffer, get may race
+// with a change of the attributes so ERANGE is retried.
+func xattrGet(get func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := get(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = get(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+func ignoreXattrErr(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENODATA) {
+		return nil
+	}
+	return err
+}
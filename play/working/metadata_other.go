@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime falls back to the modification time, the access time is not
+// portable.
+func accessTime(finfo os.FileInfo) time.Time {
+	return finfo.ModTime()
+}
+
+func copyOwner(finfo os.FileInfo, dst string) error {
+	return nil
+}
+
+func copyXattrs(src, dst string) error {
+	return nil
+}
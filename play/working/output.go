@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// tempFiles are the output files being written. They are renamed to their
+// final name once complete and removed when gozstd fails or is interrupted,
+// so a truncated output never shows up under the final name.
+var tempFiles = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// outputPath returns where the output named outName goes, with symlinks
+// resolved so the file they point to is replaced and not the link, and
+// whether it is written under a temporary name first. That is when it does
+// not exist yet or is a regular file. Anything else, like a pipe or a device,
+// is written in place.
+func outputPath(outName string) (string, bool) {
+	path, err := filepath.EvalSymlinks(outName)
+	if err != nil {
+		// A dangling symlink is followed by os.Create, write through it.
+		_, err := os.Lstat(outName)
+		return outName, err != nil
+	}
+	finfo, err := os.Stat(path)
+	return path, err == nil && finfo.Mode().IsRegular()
+}
+
+// createTemp creates an empty temporary file next to outName and returns its
+// name. It is hidden and ends in .tmp so it is easy to spot if gozstd is
+// killed before it can clean up.
+func createTemp(outName string) (string, error) {
+	dir, base := filepath.Split(outName)
+	for {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%08x.tmp", base, rand.Uint32()))
+		// Like os.Create the umask applies, os.CreateTemp would make it 0600.
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(name)
+			return "", err
+		}
+		tempFiles.Lock()
+		tempFiles.names[name] = true
+		tempFiles.Unlock()
+		return name, nil
+	}
+}
+
+// removeTemp removes the temporary file name.
+func removeTemp(name string) {
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	os.Remove(name)
+	delete(tempFiles.names, name)
+}
+
+// commitTemp renames the temporary file name to outName. With fsync the
+// content is flushed to disk first and the directory after the rename, so
+// outName survives a crash.
+func commitTemp(name, outName string, fsync bool) error {
+	if fsync {
+		if err := syncFile(name); err != nil {
+			return fmt.Errorf("failed to sync output file: %w", err)
+		}
+	}
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	if err := os.Rename(name, outName); err != nil {
+		return fmt.Errorf("failed to rename output file: %w", err)
+	}
+	delete(tempFiles.names, name)
+	if fsync {
+		// Not every system can sync a directory, Windows cannot.
+		syncFile(filepath.Dir(outName))
+	}
+	return nil
+}
+
+func syncFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// handleSignals removes the temporary files and exits on SIGINT or SIGTERM.
+// The workers stop with the process, the lock keeps a file from being
+// renamed to its final name meanwhile.
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		tempFiles.Lock()
+		for name := range tempFiles.names {
+			os.Remove(name)
+		}
+		if len(tempFiles.names) > 0 {
+			warnf("gozstd: %v, partial output removed\n", sig)
+		} else {
+			warnf("gozstd: %v\n", sig)
+		}
+		code := exitSignal + int(syscall.SIGINT)
+		if s, ok := sig.(syscall.Signal); ok {
+			code = exitSignal + int(s)
+		}
+		os.Exit(code)
+	}()
+}
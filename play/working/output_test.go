@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	tests := []struct {
+		name       string
+		wantPath   string
+		wantAtomic bool
+	}{
+		{missing, missing, true},
+		{file, file, true},
+		{dir, dir, false},
+	}
+	if runtime.GOOS != "windows" {
+		link, dangling := filepath.Join(dir, "link"), filepath.Join(dir, "dangling")
+		if err := os.Symlink(file, link); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(missing, dangling); err != nil {
+			t.Fatal(err)
+		}
+		tests = append(tests, []struct {
+			name       string
+			wantPath   string
+			wantAtomic bool
+		}{
+			// The file the link points to is replaced, not the link.
+			{link, file, true},
+			{dangling, dangling, false},
+			{os.DevNull, os.DevNull, false},
+		}...)
+	}
+	for _, tt := range tests {
+		path, atomic := outputPath(tt.name)
+		if path != tt.wantPath || atomic != tt.wantAtomic {
+			t.Errorf("outputPath(%q) = %q, %v, want %q, %v", tt.name, path, atomic, tt.wantPath, tt.wantAtomic)
+		}
+	}
+}
+
+func TestTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "a.zst")
+	if err := os.WriteFile(out, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed run removes the temporary file and keeps the old output.
+	name, err := createTemp(out)
+	if err != nil {
+		t.Fatalf("createTemp: %v", err)
+	}
+	if base := filepath.Base(name); filepath.Dir(name) != dir || !strings.HasPrefix(base, ".a.zst.") || !strings.HasSuffix(base, ".tmp") {
+		t.Errorf("createTemp(%q) = %q, want a hidden .tmp file next to it", out, name)
+	}
+	if !tempFiles.names[name] {
+		t.Errorf("%s is not removed on a signal", name)
+	}
+	removeTemp(name)
+	if _, err := os.Stat(name); !os.IsNotExist(err) || tempFiles.names[name] {
+		t.Errorf("removeTemp left %s: %v", name, err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "old" {
+		t.Errorf("the output was changed to %q", got)
+	}
+
+	for _, fsync := range []bool{false, true} {
+		name, err := createTemp(out)
+		if err != nil {
+			t.Fatalf("createTemp: %v", err)
+		}
+		if err := os.WriteFile(name, []byte("new"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := commitTemp(name, out, fsync); err != nil {
+			t.Fatalf("commitTemp with fsync %v: %v", fsync, err)
+		}
+		if got, _ := os.ReadFile(out); string(got) != "new" || tempFiles.names[name] {
+			t.Errorf("fsync %v: got %q, still a temporary file: %v", fsync, got, tempFiles.names[name])
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files left, want only a.zst", len(entries))
+	}
+
+	// Nothing is registered when the file cannot be created.
+	if _, err := createTemp(filepath.Join(dir, "missing", "a.zst")); err == nil {
+		t.Errorf("createTemp in a missing directory: got no error")
+	}
+}
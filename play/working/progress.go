@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gozstd"
+)
+
+// progressDisplay redraws a status line on stderr with the counters of a
+// gozstd.Progress until stop is called.
+type progressDisplay struct {
+	progress   *gozstd.Progress
+	total      int64 // Size of the input, 0 when unknown
+	decompress bool
+	start      time.Time
+
+	mu   sync.Mutex // Serializes the status line and warnf
+	quit chan struct{}
+	done chan struct{}
+}
+
+// display is the running progress display, if any.
+var display *progressDisplay
+
+// stderrIsTerminal reports whether stderr is a terminal, the progress line is
+// only drawn there.
+func stderrIsTerminal() bool {
+	finfo, err := os.Stderr.Stat()
+	return err == nil && finfo.Mode()&os.ModeCharDevice != 0
+}
+
+// startProgress starts drawing progress every half second. It returns nil
+// when progress is nil.
+func startProgress(progress *gozstd.Progress, total int64, decompress bool) *progressDisplay {
+	if progress == nil {
+		return nil
+	}
+	d := &progressDisplay{progress: progress, total: total, decompress: decompress, start: time.Now(), quit: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.mu.Lock()
+				d.draw()
+				d.mu.Unlock()
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+	return d
+}
+
+// stop draws the final numbers and ends the line.
+func (d *progressDisplay) stop() {
+	if d == nil {
+		return
+	}
+	close(d.quit)
+	<-d.done
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.draw()
+	fmt.Fprintln(os.Stderr)
+}
+
+func (d *progressDisplay) draw() {
+	read, written := d.progress.Read(), d.progress.Written()
+	elapsed := time.Since(d.start).Seconds()
+
+	var b strings.Builder
+	b.WriteString("\r")
+	if d.total > 0 {
+		fmt.Fprintf(&b, "%3d%% ", min(100, read*100/d.total))
+	}
+	fmt.Fprintf(&b, "read %s, written %s", formatSize(read), formatSize(written))
+
+	compressed, uncompressed := written, read
+	if d.decompress {
+		compressed, uncompressed = read, written
+	}
+	if uncompressed > 0 {
+		fmt.Fprintf(&b, ", ratio %.2f%%", float64(compressed)*100/float64(uncompressed))
+	}
+	if elapsed > 0 {
+		fmt.Fprintf(&b, ", %.1f MB/s", float64(uncompressed)/elapsed/1e6)
+	}
+	if d.total > 0 && read > 0 && read < d.total {
+		eta := time.Duration(elapsed * float64(d.total-read) / float64(read) * float64(time.Second))
+		fmt.Fprintf(&b, ", ETA %s", eta.Round(time.Second))
+	}
+	if done, read := d.progress.Chunks(); read > 0 {
+		fmt.Fprintf(&b, ", chunks %d/%d", done, read)
+	}
+	b.WriteString("\x1b[K") // Clear what is left of a longer previous line
+	os.Stderr.WriteString(b.String())
+}
+
+// warnf prints a message on stderr. It clears the progress line first, the
+// next tick draws it again.
+func warnf(format string, args ...any) {
+	if display != nil {
+		display.mu.Lock()
+		defer display.mu.Unlock()
+		os.Stderr.WriteString("\r\x1b[K")
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// inputSize adds up the sizes of the input files, stdin counts when it is a
+// regular file.
+func inputSize(names []string) int64 {
+	var total int64
+	for _, name := range names {
+		total += max(0, fileSize(name))
+	}
+	return total
+}
+
+// fileSize returns the size of the input name, "-" is stdin, or -1 when it is
+// not a regular file.
+func fileSize(name string) int64 {
+	var finfo os.FileInfo
+	var err error
+	if name == "-" {
+		finfo, err = os.Stdin.Stat()
+	} else {
+		finfo, err = os.Stat(name)
+	}
+	if err != nil || !finfo.Mode().IsRegular() {
+		return -1
+	}
+	return finfo.Size()
+}
@@ -0,0 +1,91 @@
+package gozstd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// primeDictID marks the frames compressed with the content before them as
+// dictionary. It is in the private range of dictionary IDs, so other
+// decoders report a missing dictionary instead of returning garbage.
+const primeDictID = 0x677A7072
+
+// defaultPrimeMax is the largest Prime without WindowSize, the default window
+// of the encoder.
+const defaultPrimeMax = 8 * oneMB
+
+// errNoPrime is returned when the block encoder cannot prime frames.
+var errNoPrime = withKind(fmt.Errorf("%w: priming frames with this backend", errors.ErrUnsupported), ErrUsage)
+
+// primingEncoder is implemented by block encoders which can use the input
+// before a frame as history, for Options.Prime.
+type primingEncoder interface {
+	// EncodePrimed appends the frame for src to dst. Matches may reach into
+	// history, the decoder needs the same bytes as a raw dictionary.
+	EncodePrimed(history, src, dst []byte) ([]byte, error)
+}
+
+// history returns up to n bytes of input before pos in ch, taken from the end
+// of the previous chunk when pos is near the start.
+func (ch *chunk) history(pos, n int) []byte {
+	if pos >= n {
+		return ch.in[pos-n : pos]
+	}
+	prefix := ch.prefix[len(ch.prefix)-min(len(ch.prefix), n-pos):]
+	return append(append(make([]byte, 0, len(prefix)+pos), prefix...), ch.in[:pos]...)
+}
+
+// decompressPrimed decodes the frames of a primed archive one after another,
+// each with the end of the content before it as dictionary.
+func (d *Decompressor) decompressPrimed(br *bufio.Reader, output io.Writer, meta archiveMeta) error {
+	scanner := meta.newFrameScanner(br)
+	var history []byte
+	for {
+		info, raw, err := scanner.next(true)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Skippable {
+			continue
+		}
+
+		options := append(d.opts.decoderOptions(), zstd.WithDecoderConcurrency(1))
+		if info.DictID == primeDictID {
+			options = append(options, zstd.WithDecoderDictRaw(primeDictID, history))
+		}
+		content, err := decodeFrame(raw, info, options, d.maxFrameSize())
+		if err != nil {
+			return &FrameError{Frame: scanner.frames - 1, Offset: info.Offset, Err: err}
+		}
+		if _, err := output.Write(content); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+
+		history = append(history, content...)
+		if len(history) > meta.prime {
+			history = append([]byte(nil), history[len(history)-meta.prime:]...)
+		}
+	}
+}
+
+// decodeFrame decodes the single frame raw with a decoder made for it. Up to
+// limit bytes are allocated for the content ahead.
+func decodeFrame(raw []byte, info FrameInfo, options []zstd.DOption, limit uint64) ([]byte, error) {
+	decoder, err := zstd.NewReader(nil, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+	var content []byte
+	if info.ContentSize > 0 {
+		content = make([]byte, 0, min(uint64(info.ContentSize), limit))
+	}
+	return decoder.DecodeAll(raw, content)
+}
@@ -0,0 +1,55 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPrimeRoundTrip(t *testing.T) {
+	data := testData(oneMB + 100)
+	for _, opts := range []Options{
+		{Level: 3, Threads: 4, Mode: ModeBlock, Prime: 32 << 10, FrameSize: 64 << 10},
+		// Chunks shorter than Prime take history from the chunks before.
+		{Level: 3, Threads: 4, Mode: ModeBlock, Prime: 64 << 10, FrameSize: 16 << 10, ChunkSize: 16 << 10},
+		{Level: 3, Threads: 4, Mode: ModeBlock, Prime: 32 << 10, FrameSize: 64 << 10, Adapt: true, AdaptMin: 1, AdaptMax: 6},
+	} {
+		for _, size := range []int{0, 100, len(data)} {
+			archive := compressBytes(t, opts, data[:size])
+			for _, threads := range []int{1, 4} {
+				if got := decompressBytes(t, Options{Threads: threads}, archive); !bytes.Equal(got, data[:size]) {
+					t.Fatalf("prime %d, chunk %d, %d bytes, %d threads: got %d bytes back", opts.Prime, opts.ChunkSize, size, threads, len(got))
+				}
+				d, _ := NewDecompressor(Options{Threads: threads})
+				if err := d.Test(bytes.NewReader(archive)); err != nil {
+					t.Fatalf("prime %d, chunk %d, %d bytes, %d threads: Test: %v", opts.Prime, opts.ChunkSize, size, threads, err)
+				}
+			}
+		}
+	}
+}
+
+// The history makes up for the small frames.
+func TestPrimeRatio(t *testing.T) {
+	data := testData(oneMB)
+	opts := Options{Level: 3, Threads: 4, Mode: ModeBlock, FrameSize: 16 << 10}
+	plain := compressBytes(t, opts, data)
+	opts.Prime = 64 << 10
+	primed := compressBytes(t, opts, data)
+	if len(primed) >= len(plain) {
+		t.Errorf("%d bytes primed, %d without", len(primed), len(plain))
+	}
+}
+
+func TestPrimeOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeStream, Prime: 1024},
+		{Level: 3, Threads: 2, Mode: ModeBlock, Prime: 1024, Seekable: true},
+		{Level: 3, Threads: 2, Mode: ModeBlock, Prime: defaultPrimeMax + 1},
+		{Level: 3, Threads: 2, Mode: ModeBlock, Prime: -1},
+	} {
+		if _, err := NewCompressor(opts); !errors.Is(err, ErrInvalidPrime) || !errors.Is(err, ErrUsage) {
+			t.Errorf("%+v: got %v, want ErrInvalidPrime", opts, err)
+		}
+	}
+}
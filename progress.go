@@ -0,0 +1,99 @@
+package gozstd
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Progress counts the bytes read and written by a Compressor or
+// Decompressor which has it in Options.Progress, and the chunks of block mode
+// and of parallel decoding. It may be read from another goroutine while they
+// run, and shared by several of them.
+type Progress struct {
+	read    atomic.Int64
+	written atomic.Int64
+
+	chunksRead atomic.Int64
+	chunksDone atomic.Int64
+}
+
+// Read returns the number of input bytes read so far.
+func (p *Progress) Read() int64 {
+	return p.read.Load()
+}
+
+// Written returns the number of output bytes written so far.
+func (p *Progress) Written() int64 {
+	return p.written.Load()
+}
+
+// Chunks returns the number of chunks read so far and how many of them are
+// compressed or decoded and written. The difference is what the workers and
+// the queue hold. Both are 0 in stream mode.
+func (p *Progress) Chunks() (done, read int64) {
+	return p.chunksDone.Load(), p.chunksRead.Load()
+}
+
+// The methods below do nothing on a nil Progress so the callers need no checks.
+
+func (p *Progress) addRead(n int) {
+	if p != nil {
+		p.read.Add(int64(n))
+	}
+}
+
+func (p *Progress) addWritten(n int) {
+	if p != nil {
+		p.written.Add(int64(n))
+	}
+}
+
+func (p *Progress) addChunkRead() {
+	if p != nil {
+		p.chunksRead.Add(1)
+	}
+}
+
+func (p *Progress) addChunkDone() {
+	if p != nil {
+		p.chunksDone.Add(1)
+	}
+}
+
+// reader counts the bytes read from r.
+func (p *Progress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return progressReader{r, p}
+}
+
+// writer counts the bytes written to w.
+func (p *Progress) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+	return progressWriter{w, p}
+}
+
+type progressReader struct {
+	r io.Reader
+	p *Progress
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.addRead(n)
+	return n, err
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *Progress
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.p.addWritten(n)
+	return n, err
+}
@@ -0,0 +1,38 @@
+package gozstd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	data := testData(oneMB + 100)
+	for _, tt := range []struct {
+		opts   Options
+		chunks int64
+	}{
+		{Options{Level: 3, Threads: 2, Mode: ModeStream}, 0},
+		{Options{Level: 3, Threads: 4, Mode: ModeBlock, ChunkSize: 256 << 10, FrameSize: 64 << 10}, 5},
+	} {
+		var p Progress
+		tt.opts.Progress = &p
+		archive := compressBytes(t, tt.opts, data)
+		if p.Read() != int64(len(data)) || p.Written() != int64(len(archive)) {
+			t.Errorf("%s mode: read %d and wrote %d, want %d and %d", tt.opts.Mode, p.Read(), p.Written(), len(data), len(archive))
+		}
+		if done, read := p.Chunks(); done != tt.chunks || read != tt.chunks {
+			t.Errorf("%s mode: %d of %d chunks, want %d", tt.opts.Mode, done, read, tt.chunks)
+		}
+
+		var dp Progress
+		if got := decompressBytes(t, Options{Threads: 4, Progress: &dp}, archive); !bytes.Equal(got, data) {
+			t.Fatalf("%s mode: got %d bytes back", tt.opts.Mode, len(got))
+		}
+		if dp.Read() != int64(len(archive)) || dp.Written() != int64(len(data)) {
+			t.Errorf("%s mode: decoding read %d and wrote %d, want %d and %d", tt.opts.Mode, dp.Read(), dp.Written(), len(archive), len(data))
+		}
+		if done, read := dp.Chunks(); done != read {
+			t.Errorf("%s mode: decoded %d of %d chunks", tt.opts.Mode, done, read)
+		}
+	}
+}
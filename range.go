@@ -0,0 +1,155 @@
+package gozstd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecompressRange writes length bytes of the uncompressed content starting at
+// offset to output. A negative length means up to the end. The archive of the
+// given size is read from input.
+//
+// When the archive has a seek table only the frames covering the range are
+// read. Otherwise the frame headers are scanned and frames which record their
+// content size and end before offset are skipped without decoding them.
+// Archives made with Prime are decoded from the start.
+func (d *Decompressor) DecompressRange(input io.ReaderAt, size int64, output io.Writer, offset, length int64) (err error) {
+	if offset < 0 {
+		return withKind(fmt.Errorf("invalid range offset %d", offset), ErrUsage)
+	}
+	defer func() { err = decodeError(err) }()
+	input, output = ioReaderAt{input}, ioWriter{output}
+
+	sr, err := d.NewSeekableReader(input, size)
+	if err == nil {
+		defer sr.Close()
+		if length < 0 || offset+length > sr.Size() {
+			length = sr.Size() - offset
+		}
+		if length <= 0 {
+			return nil
+		}
+		if _, err := io.Copy(output, io.NewSectionReader(sr, offset, length)); err != nil {
+			return fmt.Errorf("failed to decompress range: %w", err)
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrNoSeekTable) {
+		return err
+	}
+	return d.decompressRangeScan(input, size, output, offset, length)
+}
+
+func (d *Decompressor) decompressRangeScan(input io.ReaderAt, size int64, output io.Writer, offset, length int64) error {
+	if length == 0 {
+		return nil
+	}
+	br := bufio.NewReaderSize(io.NewSectionReader(input, 0, size), 1<<16)
+	meta, err := readMeta(br)
+	if err != nil {
+		return err
+	}
+	d = d.withMeta(meta)
+	decoder, err := zstd.NewReader(nil, append(d.opts.decoderOptions(), zstd.WithDecoderConcurrency(1))...)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	if meta.prime != 0 {
+		// Primed frames need all the content before them.
+		err := d.decompressPrimed(br, &rangeWriter{w: output, skip: offset, left: length}, meta)
+		if errors.Is(err, errRangeDone) {
+			return nil
+		}
+		return err
+	}
+
+	scanner := meta.newFrameScanner(br)
+	var pos int64 // position in the uncompressed content
+	for length < 0 || pos < offset+length {
+		info, _, err := scanner.next(false)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Skippable {
+			continue
+		}
+		if info.ContentSize >= 0 && pos+info.ContentSize <= offset {
+			pos += info.ContentSize
+			continue
+		}
+
+		// This frame overlaps the range or its size is unknown, decode it
+		// again from the archive.
+		if err := decoder.Reset(io.NewSectionReader(input, info.Offset, info.Size)); err != nil {
+			return fmt.Errorf("failed to decompress frame at offset %d: %w", info.Offset, err)
+		}
+		var skip int64
+		if pos < offset {
+			skip, err = io.CopyN(io.Discard, decoder, offset-pos)
+			pos += skip
+			if err == io.EOF {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to decompress frame at offset %d: %w", info.Offset, err)
+			}
+		}
+		var n int64
+		if length < 0 {
+			n, err = io.Copy(output, decoder)
+		} else {
+			n, err = io.CopyN(output, decoder, offset+length-pos)
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		pos += n
+		if err != nil {
+			return fmt.Errorf("failed to decompress frame at offset %d: %w", info.Offset, err)
+		}
+	}
+	return nil
+}
+
+// errRangeDone stops decoding once rangeWriter has the whole range.
+var errRangeDone = errors.New("range done")
+
+// rangeWriter passes on the part of what is written to it which is in the
+// range, and fails with errRangeDone after its end. A negative left means up
+// to the end.
+type rangeWriter struct {
+	w    io.Writer
+	skip int64
+	left int64
+}
+
+func (r *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if r.skip >= int64(n) {
+		r.skip -= int64(n)
+		return n, nil
+	}
+	p, r.skip = p[r.skip:], 0
+	if r.left >= 0 && int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	if _, err := r.w.Write(p); err != nil {
+		return 0, err
+	}
+	if r.left >= 0 {
+		r.left -= int64(len(p))
+		if r.left == 0 {
+			return n, errRangeDone
+		}
+	}
+	return n, nil
+}
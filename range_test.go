@@ -0,0 +1,64 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecompressRange(t *testing.T) {
+	data := testData(oneMB + 100)
+	archives := []struct {
+		name string
+		opts Options
+	}{
+		{"stream", Options{Level: 3, Threads: 2, Mode: ModeStream}},
+		{"block", Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}},
+		{"seekable", Options{Level: 3, Threads: 2, Mode: ModeBlock, Seekable: true, FrameSize: 64 << 10}},
+		{"prime", Options{Level: 3, Threads: 2, Mode: ModeBlock, Prime: 16 << 10, FrameSize: 64 << 10}},
+	}
+	ranges := []struct{ offset, length int64 }{
+		{0, 10},
+		{65530, 20},
+		{100 << 10, 300 << 10},
+		{int64(len(data)) - 5, 5},
+		{int64(len(data)) - 5, 100},
+		{500 << 10, -1},
+		{int64(len(data)), 10},
+		{int64(len(data)) + 10, 10},
+		{10, 0},
+	}
+	for _, a := range archives {
+		t.Run(a.name, func(t *testing.T) {
+			archive := compressBytes(t, a.opts, data)
+			d, _ := NewDecompressor(Options{Threads: 2})
+			for _, r := range ranges {
+				var out bytes.Buffer
+				if err := d.DecompressRange(bytes.NewReader(archive), int64(len(archive)), &out, r.offset, r.length); err != nil {
+					t.Fatalf("DecompressRange(%d, %d): %v", r.offset, r.length, err)
+				}
+				end := int64(len(data))
+				if r.length >= 0 {
+					end = min(end, r.offset+r.length)
+				}
+				want := data[min(r.offset, end):end]
+				if !bytes.Equal(out.Bytes(), want) {
+					t.Errorf("DecompressRange(%d, %d): got %d bytes, want %d", r.offset, r.length, out.Len(), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestDecompressRangeErrors(t *testing.T) {
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, testData(256<<10))
+	d, _ := NewDecompressor(Options{Threads: 2})
+	var out bytes.Buffer
+	if err := d.DecompressRange(bytes.NewReader(archive), int64(len(archive)), &out, -1, 10); !errors.Is(err, ErrUsage) {
+		t.Errorf("negative offset: got %v, want ErrUsage", err)
+	}
+	cut := archive[:len(archive)-10]
+	if err := d.DecompressRange(bytes.NewReader(cut), int64(len(cut)), &out, 200<<10, 10); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("truncated archive: got %v, want ErrCorrupt", err)
+	}
+}
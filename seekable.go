@@ -0,0 +1,244 @@
+package gozstd
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultFrameCache is the number of decoded frames a SeekableReader keeps.
+const defaultFrameCache = 8
+
+// seekFrame locates one frame both in the archive and in the content.
+type seekFrame struct {
+	cOffset int64
+	dOffset int64
+	cSize   int64
+	dSize   int64
+}
+
+// SeekableReader reads the uncompressed content of an archive with a seek
+// table, as written by block mode with Seekable. Only the frames covering the
+// requested range are decoded. It is safe for concurrent use.
+type SeekableReader struct {
+	r      io.ReaderAt
+	closer io.Closer
+	frames []seekFrame
+	size   int64
+
+	decoder *zstd.Decoder
+	limit   int64 // Content allocated ahead for a frame, the seek table is not trusted
+
+	// posMu is held for a whole Read or Seek so concurrent calls never
+	// read the same bytes.
+	posMu  sync.Mutex
+	offset int64
+
+	mu    sync.Mutex
+	cache *frameCache
+}
+
+// OpenSeekable opens the archive name for reading. The caller must call Close.
+func OpenSeekable(name string) (*SeekableReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", withKind(err, ErrIO))
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat input file: %w", withKind(err, ErrIO))
+	}
+	sr, err := NewSeekableReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sr.closer = f
+	return sr, nil
+}
+
+// NewSeekableReader returns a SeekableReader reading the archive of the given
+// size from r. It returns ErrNoSeekTable if the archive has no seek table.
+func NewSeekableReader(r io.ReaderAt, size int64) (*SeekableReader, error) {
+	return newSeekableReader(r, size, nil, maxParallelFrameSize)
+}
+
+// NewSeekableReader is like the NewSeekableReader function but decodes the
+// frames with the options of d, its dictionary for example.
+func (d *Decompressor) NewSeekableReader(r io.ReaderAt, size int64) (*SeekableReader, error) {
+	return newSeekableReader(r, size, d.opts.decoderOptions(), d.maxFrameSize())
+}
+
+func newSeekableReader(r io.ReaderAt, size int64, options []zstd.DOption, limit uint64) (*SeekableReader, error) {
+	r = ioReaderAt{r}
+	table, err := readSeekTable(r, size)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	decoder, err := zstd.NewReader(nil, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+
+	sr := &SeekableReader{r: r, decoder: decoder, limit: int64(limit), cache: newFrameCache(defaultFrameCache)}
+	sr.frames = make([]seekFrame, len(table.Entries))
+	var cOffset, dOffset int64
+	for i, e := range table.Entries {
+		sr.frames[i] = seekFrame{cOffset: cOffset, dOffset: dOffset, cSize: int64(e.CompressedSize), dSize: int64(e.DecompressedSize)}
+		cOffset += int64(e.CompressedSize)
+		dOffset += int64(e.DecompressedSize)
+	}
+	sr.size = dOffset
+	return sr, nil
+}
+
+// Size returns the uncompressed size of the archive.
+func (sr *SeekableReader) Size() int64 {
+	return sr.size
+}
+
+// NumFrames returns the number of frames in the seek table.
+func (sr *SeekableReader) NumFrames() int {
+	return len(sr.frames)
+}
+
+// ReadAt implements io.ReaderAt over the uncompressed content.
+func (sr *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		if off >= sr.size {
+			return n, io.EOF
+		}
+		i := sort.Search(len(sr.frames), func(i int) bool {
+			return sr.frames[i].dOffset+sr.frames[i].dSize > off
+		})
+		data, err := sr.frame(i)
+		if err != nil {
+			return n, err
+		}
+		m := copy(p[n:], data[off-sr.frames[i].dOffset:])
+		n += m
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (sr *SeekableReader) Read(p []byte) (int, error) {
+	sr.posMu.Lock()
+	defer sr.posMu.Unlock()
+
+	n, err := sr.ReadAt(p, sr.offset)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	sr.offset += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (sr *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	sr.posMu.Lock()
+	defer sr.posMu.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.offset
+	case io.SeekEnd:
+		offset += sr.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	sr.offset = offset
+	return offset, nil
+}
+
+// Close releases the decoder and closes the file opened by OpenSeekable.
+func (sr *SeekableReader) Close() error {
+	sr.decoder.Close()
+	if sr.closer != nil {
+		return sr.closer.Close()
+	}
+	return nil
+}
+
+// frame returns the decoded content of frame i, from the cache if possible.
+func (sr *SeekableReader) frame(i int) ([]byte, error) {
+	sr.mu.Lock()
+	data, ok := sr.cache.get(i)
+	sr.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	f := sr.frames[i]
+	raw := make([]byte, f.cSize)
+	if _, err := sr.r.ReadAt(raw, f.cOffset); err != nil {
+		return nil, fmt.Errorf("failed to read frame %d: %w", i, err)
+	}
+	// DecodeAll verifies the content checksum of the frame when it has one.
+	data, err := sr.decoder.DecodeAll(raw, make([]byte, 0, min(f.dSize, sr.limit)))
+	if err != nil {
+		return nil, decodeError(fmt.Errorf("failed to decompress frame %d: %w", i, err))
+	}
+	if int64(len(data)) != f.dSize {
+		return nil, decodeError(fmt.Errorf("%w: frame %d has %d bytes, seek table says %d", ErrBadSeekTable, i, len(data), f.dSize))
+	}
+
+	sr.mu.Lock()
+	sr.cache.put(i, data)
+	sr.mu.Unlock()
+	return data, nil
+}
+
+// frameCache is a small LRU cache of decoded frames.
+type frameCache struct {
+	size  int
+	order *list.List
+	items map[int]*list.Element
+}
+
+type cachedFrame struct {
+	index int
+	data  []byte
+}
+
+func newFrameCache(size int) *frameCache {
+	return &frameCache{size: size, order: list.New(), items: make(map[int]*list.Element)}
+}
+
+func (c *frameCache) get(index int) ([]byte, bool) {
+	e, ok := c.items[index]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cachedFrame).data, true
+}
+
+func (c *frameCache) put(index int, data []byte) {
+	if e, ok := c.items[index]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[index] = c.order.PushFront(&cachedFrame{index: index, data: data})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*cachedFrame).index)
+	}
+}
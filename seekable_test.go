@@ -0,0 +1,93 @@
+package gozstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestSeekableReader(t *testing.T) {
+	data := testData(oneMB)
+	archive := compressBytes(t, Options{Level: 3, Threads: 4, Mode: ModeBlock, Seekable: true, SeekChecksums: true, FrameSize: 64 << 10}, data)
+	sr, err := NewSeekableReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader: %v", err)
+	}
+	defer sr.Close()
+	if sr.Size() != int64(len(data)) {
+		t.Fatalf("Size is %d, want %d", sr.Size(), len(data))
+	}
+	for _, r := range []struct{ off, n int }{{0, 10}, {65530, 20}, {100 << 10, 300 << 10}, {len(data) - 5, 5}} {
+		p := make([]byte, r.n)
+		if _, err := sr.ReadAt(p, int64(r.off)); err != nil && err != io.EOF {
+			t.Fatalf("ReadAt(%d, %d): %v", r.off, r.n, err)
+		}
+		if !bytes.Equal(p, data[r.off:r.off+r.n]) {
+			t.Errorf("ReadAt(%d, %d) returned other data", r.off, r.n)
+		}
+	}
+	if _, err := sr.Seek(3000, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err := io.ReadAll(sr)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(rest, data[3000:]) {
+		t.Errorf("Read after Seek returned other data")
+	}
+}
+
+// Concurrent Reads each get their own part of the content, together all of it.
+func TestSeekableReaderConcurrentRead(t *testing.T) {
+	data := testData(oneMB)
+	archive := compressBytes(t, Options{Level: 3, Threads: 4, Mode: ModeBlock, Seekable: true, FrameSize: 64 << 10}, data)
+	sr, err := NewSeekableReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader: %v", err)
+	}
+	defer sr.Close()
+	var mu sync.Mutex
+	var total int
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 10000)
+			for {
+				n, err := sr.Read(p)
+				mu.Lock()
+				total += n
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if total != len(data) {
+		t.Errorf("read %d bytes, want %d", total, len(data))
+	}
+}
+
+// A seek table entry larger than the frame is reported, not allocated.
+func TestSeekableReaderBadEntry(t *testing.T) {
+	data := testData(256 << 10)
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, Seekable: true, FrameSize: 64 << 10}, data)
+	// The first entry follows the skippable frame header of the 4 entries.
+	entries := len(archive) - seekFooterSize - 4*8
+	binary.LittleEndian.PutUint32(archive[entries+4:], 1<<32-1)
+	sr, err := NewSeekableReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader: %v", err)
+	}
+	defer sr.Close()
+	if _, err := sr.ReadAt(make([]byte, 10), 0); !errors.Is(err, ErrBadSeekTable) || !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("got %v, want ErrBadSeekTable", err)
+	}
+}
@@ -0,0 +1,137 @@
+package gozstd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Constants of the zstd seekable format, see
+// https://github.com/facebook/zstd/blob/dev/contrib/seekable_format/zstd_seekable_compression_format.md
+const (
+	seekTableMagic    = 0x184D2A5E
+	seekableMagic     = 0x8F92EAB1
+	seekFooterSize    = 9
+	seekChecksumFlag  = 1 << 7
+	seekMaxFrameCount = 1 << 27
+)
+
+// seekEntry is the seek table entry of one frame.
+type seekEntry struct {
+	CompressedSize   uint32
+	DecompressedSize uint32
+	Checksum         uint32
+}
+
+// seekTable is the index of a seekable archive.
+type seekTable struct {
+	Entries     []seekEntry
+	HasChecksum bool
+}
+
+// add records the frame made from content. When the frame has a content
+// checksum it is in its last 4 bytes, which is exactly what the seek table
+// wants, so there is no need to hash the data again.
+func (t *seekTable) add(frame, content []byte) {
+	e := seekEntry{CompressedSize: uint32(len(frame)), DecompressedSize: uint32(len(content))}
+	if t.HasChecksum {
+		if len(frame) > 4 && frame[4]&(1<<2) != 0 {
+			e.Checksum = binary.LittleEndian.Uint32(frame[len(frame)-4:])
+		} else {
+			e.Checksum = uint32(xxhash.Sum64(content))
+		}
+	}
+	t.Entries = append(t.Entries, e)
+}
+
+// appendTo appends the seek table as a skippable frame to dst.
+func (t *seekTable) appendTo(dst []byte) []byte {
+	entrySize := 8
+	if t.HasChecksum {
+		entrySize = 12
+	}
+	dst = binary.LittleEndian.AppendUint32(dst, seekTableMagic)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(t.Entries)*entrySize+seekFooterSize))
+	for _, e := range t.Entries {
+		dst = binary.LittleEndian.AppendUint32(dst, e.CompressedSize)
+		dst = binary.LittleEndian.AppendUint32(dst, e.DecompressedSize)
+		if t.HasChecksum {
+			dst = binary.LittleEndian.AppendUint32(dst, e.Checksum)
+		}
+	}
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(t.Entries)))
+	var desc byte
+	if t.HasChecksum {
+		desc |= seekChecksumFlag
+	}
+	dst = append(dst, desc)
+	return binary.LittleEndian.AppendUint32(dst, seekableMagic)
+}
+
+// writeTo writes the seek table as a skippable frame to w.
+func (t *seekTable) writeTo(w io.Writer) error {
+	if len(t.Entries) > seekMaxFrameCount {
+		return fmt.Errorf("too many frames for a seek table: %d", len(t.Entries))
+	}
+	if _, err := w.Write(t.appendTo(nil)); err != nil {
+		return fmt.Errorf("failed to write seek table: %w", err)
+	}
+	return nil
+}
+
+// readSeekTable reads the seek table at the end of an archive of the given size.
+func readSeekTable(r io.ReaderAt, size int64) (*seekTable, error) {
+	if size < seekFooterSize+8 {
+		return nil, ErrNoSeekTable
+	}
+	var footer [seekFooterSize]byte
+	if _, err := r.ReadAt(footer[:], size-seekFooterSize); err != nil {
+		return nil, fmt.Errorf("failed to read seek table footer: %w", err)
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, ErrNoSeekTable
+	}
+	numFrames := int64(binary.LittleEndian.Uint32(footer[:4]))
+	desc := footer[4]
+	if desc&0x7c != 0 {
+		return nil, fmt.Errorf("%w: reserved bits set in seek table descriptor", ErrBadSeekTable)
+	}
+	if numFrames > seekMaxFrameCount {
+		return nil, fmt.Errorf("%w: too many frames %d", ErrBadSeekTable, numFrames)
+	}
+
+	t := &seekTable{HasChecksum: desc&seekChecksumFlag != 0}
+	entrySize := int64(8)
+	if t.HasChecksum {
+		entrySize = 12
+	}
+	frameSize := numFrames*entrySize + seekFooterSize
+	if frameSize+8 > size {
+		return nil, fmt.Errorf("%w: seek table larger than the file", ErrBadSeekTable)
+	}
+	buf := make([]byte, frameSize+8)
+	if _, err := r.ReadAt(buf, size-int64(len(buf))); err != nil {
+		return nil, fmt.Errorf("failed to read seek table: %w", err)
+	}
+	if binary.LittleEndian.Uint32(buf) != seekTableMagic || int64(binary.LittleEndian.Uint32(buf[4:])) != frameSize {
+		return nil, fmt.Errorf("%w: bad skippable frame header", ErrBadSeekTable)
+	}
+
+	var total int64
+	t.Entries = make([]seekEntry, numFrames)
+	for i := range t.Entries {
+		e := buf[8+int64(i)*entrySize:]
+		t.Entries[i].CompressedSize = binary.LittleEndian.Uint32(e)
+		t.Entries[i].DecompressedSize = binary.LittleEndian.Uint32(e[4:])
+		if t.HasChecksum {
+			t.Entries[i].Checksum = binary.LittleEndian.Uint32(e[8:])
+		}
+		total += int64(t.Entries[i].CompressedSize)
+	}
+	if total != size-int64(len(buf)) {
+		return nil, fmt.Errorf("%w: frames cover %d bytes but the archive has %d", ErrBadSeekTable, total, size-int64(len(buf)))
+	}
+	return t, nil
+}
@@ -0,0 +1,97 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os/exec"
+	"testing"
+)
+
+func TestSeekTable(t *testing.T) {
+	for _, checksums := range []bool{false, true} {
+		data := testData(oneMB + 100)
+		archive := compressBytes(t, Options{Level: 3, Threads: 4, Mode: ModeBlock, Seekable: true, SeekChecksums: checksums, FrameSize: 64 << 10}, data)
+		table, err := readSeekTable(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			t.Fatalf("readSeekTable: %v", err)
+		}
+		if table.HasChecksum != checksums {
+			t.Errorf("HasChecksum is %v, want %v", table.HasChecksum, checksums)
+		}
+		if len(table.Entries) != 17 {
+			t.Errorf("got %d frames, want 17", len(table.Entries))
+		}
+		var content int
+		for _, e := range table.Entries {
+			content += int(e.DecompressedSize)
+		}
+		if content != len(data) {
+			t.Errorf("seek table holds %d bytes of content, want %d", content, len(data))
+		}
+		if got := table.appendTo(nil); !bytes.HasSuffix(archive, got) {
+			t.Errorf("the seek table does not write back the same")
+		}
+		if got := decompressBytes(t, Options{Threads: 4}, archive); !bytes.Equal(got, data) {
+			t.Errorf("got %d bytes back, want %d", len(got), len(data))
+		}
+	}
+}
+
+func TestSeekTableErrors(t *testing.T) {
+	data := testData(256 << 10)
+	plain := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, data)
+	if _, err := readSeekTable(bytes.NewReader(plain), int64(len(plain))); err != ErrNoSeekTable {
+		t.Errorf("archive without seek table: got %v, want ErrNoSeekTable", err)
+	}
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, Seekable: true, FrameSize: 64 << 10}, data)
+	// A frame less in front of the seek table.
+	table, _ := readSeekTable(bytes.NewReader(archive), int64(len(archive)))
+	cut := append([]byte(nil), archive[table.Entries[0].CompressedSize:]...)
+	if _, err := readSeekTable(bytes.NewReader(cut), int64(len(cut))); !errors.Is(err, ErrBadSeekTable) {
+		t.Errorf("archive missing a frame: got %v, want ErrBadSeekTable", err)
+	}
+	// The number of frames in the footer larger than the file.
+	bad := append([]byte(nil), archive...)
+	bad[len(bad)-seekFooterSize+2] = 0x01
+	if _, err := readSeekTable(bytes.NewReader(bad), int64(len(bad))); !errors.Is(err, ErrBadSeekTable) {
+		t.Errorf("too many frames: got %v, want ErrBadSeekTable", err)
+	}
+}
+
+func TestSeekableFrameSizeLimit(t *testing.T) {
+	large := uint64(math.MaxUint32) + 1
+	if uint64(int(large)) != large {
+		t.Skip("int cannot hold a frame larger than the seek table")
+	}
+	_, err := NewCompressor(Options{Level: 3, Threads: 2, Mode: ModeBlock, Seekable: true, FrameSize: int(large)})
+	if !errors.Is(err, ErrInvalidChunkSize) || !errors.Is(err, ErrUsage) {
+		t.Fatalf("got %v, want ErrInvalidChunkSize", err)
+	}
+	if _, err := NewCompressor(Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: int(large)}); err != nil {
+		t.Fatalf("without seek table: %v", err)
+	}
+}
+
+// Seekable archives are plain zstd files to the zstd tool.
+func TestSeekableZstdCompat(t *testing.T) {
+	zstdPath, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd not found")
+	}
+	for _, size := range []int{0, 1000, oneMB + 100} {
+		data := testData(size)
+		for _, checksums := range []bool{false, true} {
+			archive := compressBytes(t, Options{Level: 3, Threads: 4, Mode: ModeBlock, Seekable: true, SeekChecksums: checksums, FrameSize: 64 << 10}, data)
+			cmd := exec.Command(zstdPath, "-d", "-c", "-q")
+			cmd.Stdin = bytes.NewReader(archive)
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("%d bytes: zstd -d: %v", size, err)
+			}
+			if !bytes.Equal(out, data) {
+				t.Fatalf("%d bytes: zstd -d returned %d bytes", size, len(out))
+			}
+		}
+	}
+}
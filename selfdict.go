@@ -0,0 +1,103 @@
+package gozstd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// selfDictSampleSize is how much input is sampled to train the dictionary of
+// SelfDict, about 100 times the dictionary as zstd recommends.
+const selfDictSampleSize = 100 * DefaultMaxDictSize
+
+// selfDictPiece is the size of one training sample.
+const selfDictPiece = 4 << 10
+
+// sampleInput returns training samples taken from input and the reader to
+// compress from. When input is a regular file the samples are spread over
+// all of it and it is read again from where it was. Otherwise they come
+// from the first selfDictSampleSize bytes, which are read ahead.
+func sampleInput(input io.Reader) ([][]byte, io.Reader, error) {
+	if f, ok := input.(io.ReadSeeker); ok {
+		if size := readerSize(input); size >= 0 {
+			samples, err := sampleFile(f, size)
+			return samples, input, err
+		}
+	}
+	br := bufio.NewReaderSize(input, selfDictSampleSize)
+	data, err := br.Peek(selfDictSampleSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return splitSamples(data), br, nil
+}
+
+// sampleFile reads selfDictSampleSize bytes in pieces spread evenly from the
+// current offset of f to size, then seeks back.
+func sampleFile(f io.ReadSeeker, size int64) ([][]byte, error) {
+	start, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek input: %w", err)
+	}
+	remaining := size - start
+	pieces := int64(selfDictSampleSize / selfDictPiece)
+	if remaining <= selfDictSampleSize {
+		pieces = remaining / selfDictPiece
+	}
+
+	var samples [][]byte
+	for i := int64(0); i < pieces; i++ {
+		if _, err := f.Seek(start+i*remaining/pieces, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek input: %w", err)
+		}
+		piece := make([]byte, selfDictPiece)
+		if _, err := io.ReadFull(f, piece); err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		samples = append(samples, piece)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek input: %w", err)
+	}
+	return samples, nil
+}
+
+// splitSamples cuts data into samples of selfDictPiece bytes.
+func splitSamples(data []byte) [][]byte {
+	var samples [][]byte
+	for len(data) >= selfDictPiece {
+		samples = append(samples, append([]byte(nil), data[:selfDictPiece]...))
+		data = data[selfDictPiece:]
+	}
+	return samples
+}
+
+// trainSelfDict samples input and trains the dictionary of SelfDict. The
+// dictionary is nil when the input is too small to be worth one, or has
+// nothing a dictionary could be trained on, like random data.
+func (c *Compressor) trainSelfDict(input io.Reader) ([]byte, io.Reader, error) {
+	samples, input, err := sampleInput(input)
+	if err != nil {
+		return nil, nil, withKind(err, ErrIO)
+	}
+	// Below a full sample the stored dictionary costs more than it saves.
+	if len(samples) < selfDictSampleSize/selfDictPiece {
+		return nil, input, nil
+	}
+	dict, err := TrainDict(samples, DefaultMaxDictSize, c.opts.Level)
+	if err != nil {
+		return nil, input, nil
+	}
+	return dict, input, nil
+}
+
+// withMeta returns a Decompressor for an archive with meta: one using the
+// embedded dictionary of SelfDict archives, d for the others.
+func (d *Decompressor) withMeta(meta archiveMeta) *Decompressor {
+	if meta.dict == nil {
+		return d
+	}
+	o := d.opts
+	o.Dict = meta.dict
+	return &Decompressor{opts: o}
+}
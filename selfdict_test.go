@@ -0,0 +1,79 @@
+package gozstd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// selfDictOptions makes small frames, where the dictionary pays off.
+var selfDictOptions = Options{Level: 3, Threads: 4, Mode: ModeBlock, SelfDict: true, FrameSize: 16 << 10}
+
+// checkSelfDict checks that archive decompresses to data and has a
+// dictionary frame or not, as want says.
+func checkSelfDict(t *testing.T, archive, data []byte, want bool) {
+	t.Helper()
+	meta, err := readMeta(bufio.NewReader(bytes.NewReader(archive)))
+	if err != nil {
+		t.Fatalf("readMeta: %v", err)
+	}
+	if got := meta.dict != nil; got != want {
+		t.Fatalf("dictionary frame: got %v, want %v", got, want)
+	}
+	for _, threads := range []int{1, 4} {
+		if got := decompressBytes(t, Options{Threads: threads}, archive); !bytes.Equal(got, data) {
+			t.Fatalf("%d threads: got %d bytes back, want %d", threads, len(got), len(data))
+		}
+	}
+	d, _ := NewDecompressor(Options{Threads: 4})
+	if err := d.Test(bytes.NewReader(archive)); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+}
+
+func TestSelfDict(t *testing.T) {
+	data := testData(selfDictSampleSize + oneMB)
+	checkSelfDict(t, compressBytes(t, selfDictOptions, data), data, true)
+
+	// Below a full sample no dictionary is stored.
+	for _, size := range []int{0, 100, selfDictSampleSize - 1} {
+		checkSelfDict(t, compressBytes(t, selfDictOptions, data[:size]), data[:size], false)
+	}
+}
+
+// A file is sampled over all of it and read again from the start.
+func TestSelfDictFile(t *testing.T) {
+	data := testData(selfDictSampleSize + oneMB)
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in"), filepath.Join(dir, "in.zst")
+	if err := os.WriteFile(input, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCompressor(selfDictOptions)
+	if err != nil {
+		t.Fatalf("NewCompressor: %v", err)
+	}
+	if err := c.CompressFile(input, output); err != nil {
+		t.Fatalf("CompressFile: %v", err)
+	}
+	archive, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSelfDict(t, archive, data, true)
+}
+
+func TestSelfDictOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeStream, SelfDict: true},
+		{Level: 3, Threads: 2, Mode: ModeBlock, SelfDict: true, Seekable: true},
+		{Level: 3, Threads: 2, Mode: ModeBlock, SelfDict: true, Prime: 1024},
+	} {
+		if _, err := NewCompressor(opts); !errors.Is(err, ErrSelfDictMode) || !errors.Is(err, ErrUsage) {
+			t.Errorf("%+v: got %v, want ErrSelfDictMode", opts, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package gozstd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Test decodes input and throws the output away. Content checksums are
+// verified for frames which have one. The first bad frame is reported as a
+// *FrameError. Files made of small frames, as written by block mode, are
+// checked on Threads workers.
+func (d *Decompressor) Test(input io.Reader) (err error) {
+	defer func() { err = decodeError(err) }()
+	br := bufio.NewReaderSize(d.opts.Progress.reader(ioReader{input}), 1<<16)
+	meta, err := readMeta(br)
+	if err != nil {
+		return err
+	}
+	d = d.withMeta(meta)
+	if meta.prime != 0 {
+		return d.decompressPrimed(br, io.Discard, meta)
+	}
+	scanner := meta.newFrameScanner(br)
+	if d.opts.Threads > 1 && hasSmallFrames(br, d.maxFrameSize()) {
+		// From a frame too large for it on the frames are tested serially.
+		if err := d.testParallel(scanner, meta); err != errLargeFrame {
+			return err
+		}
+	}
+	return d.testSerial(scanner)
+}
+
+func (d *Decompressor) testParallel(scanner *frameScanner, meta archiveMeta) error {
+	decoder, err := zstd.NewReader(nil, append(d.opts.decoderOptions(), zstd.WithDecoderConcurrency(d.opts.Threads))...)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	next := func() (*chunk, error) {
+		if !frameFits(scanner.r, d.maxFrameSize()) {
+			return nil, errLargeFrame
+		}
+		info, raw, err := scanner.next(true)
+		if err != nil {
+			return nil, err
+		}
+		return &chunk{in: raw, offset: info.Offset}, nil
+	}
+	work := func(ch *chunk) {
+		if _, err := decoder.DecodeAll(ch.in, nil); err != nil {
+			ch.err = &FrameError{Frame: meta.frames + ch.index, Offset: ch.offset, Err: err}
+		}
+		ch.in = nil
+	}
+	write := func(ch *chunk) error {
+		return nil
+	}
+
+	return runOrdered(d.opts.Threads, d.opts.Progress, next, work, write)
+}
+
+// testSerial streams every frame through its own pipe into the decoder, so a
+// failure can be tied to a frame without holding the frame in memory.
+func (d *Decompressor) testSerial(scanner *frameScanner) error {
+	decoder, err := zstd.NewReader(nil, d.opts.decoderOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	type scanResult struct {
+		info FrameInfo
+		err  error
+	}
+	for index := scanner.frames; ; index++ {
+		pr, pw := io.Pipe()
+		scanner.sink = pw
+		scanned := make(chan scanResult, 1)
+		go func() {
+			info, _, err := scanner.next(false)
+			pw.CloseWithError(err)
+			scanned <- scanResult{info, err}
+		}()
+
+		err := decoder.Reset(pr)
+		if err == nil {
+			_, err = io.Copy(io.Discard, decoder)
+		}
+		pr.CloseWithError(io.ErrClosedPipe)
+		res := <-scanned
+
+		if res.err == io.EOF {
+			return nil
+		}
+		// The scanner fails with ErrClosedPipe when the decoder gave up first.
+		if err != nil && (res.err == nil || errors.Is(res.err, io.ErrClosedPipe)) {
+			return &FrameError{Frame: index, Offset: res.info.Offset, Err: err}
+		}
+		if res.err != nil {
+			return res.err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gozstd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	for _, opts := range []Options{
+		{Level: 3, Threads: 2, Mode: ModeStream},
+		{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10},
+	} {
+		for _, size := range []int{0, 100, oneMB} {
+			archive := compressBytes(t, opts, testData(size))
+			for _, threads := range []int{1, 4} {
+				d, _ := NewDecompressor(Options{Threads: threads})
+				if err := d.Test(bytes.NewReader(archive)); err != nil {
+					t.Errorf("%s mode, %d bytes, %d threads: %v", opts.Mode, size, threads, err)
+				}
+			}
+		}
+	}
+}
+
+// Frames too large to test in parallel are tested one after another.
+func TestTestLargeFrames(t *testing.T) {
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, testData(oneMB))
+	archive = append(archive, compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: oneMB}, testData(2*oneMB))...)
+	d, _ := NewDecompressor(Options{Threads: 2, MaxMemory: oneMB})
+	if err := d.Test(bytes.NewReader(archive)); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	archive[len(archive)-100] ^= 0xFF
+	if err := d.Test(bytes.NewReader(archive)); err == nil {
+		t.Fatalf("Test of a corrupt large frame did not fail")
+	}
+}
+
+func TestTestErrors(t *testing.T) {
+	archive := compressBytes(t, Options{Level: 3, Threads: 2, Mode: ModeBlock, FrameSize: 64 << 10}, testData(256<<10))
+	var frames []FrameInfo
+	if err := ListFrames(bytes.NewReader(archive), func(info FrameInfo) error {
+		frames = append(frames, info)
+		return nil
+	}); err != nil {
+		t.Fatalf("ListFrames: %v", err)
+	}
+	if !frames[1].HasChecksum {
+		t.Skipf("%s writes no content checksums", DefaultBackend().Name())
+	}
+
+	for _, threads := range []int{1, 4} {
+		d, _ := NewDecompressor(Options{Threads: threads})
+
+		// The content checksum ends the frame.
+		bad := append([]byte(nil), archive...)
+		bad[frames[1].Offset+frames[1].Size-1] ^= 0xFF
+		err := d.Test(bytes.NewReader(bad))
+		var frameErr *FrameError
+		if !errors.Is(err, ErrChecksum) || !errors.As(err, &frameErr) || frameErr.Frame != 1 || frameErr.Offset != frames[1].Offset {
+			t.Errorf("%d threads, bad checksum: got %v, want ErrChecksum in frame 1", threads, err)
+		}
+
+		err = d.Test(bytes.NewReader(archive[:frames[2].Offset+10]))
+		if !errors.Is(err, ErrCorrupt) || !errors.As(err, &frameErr) || frameErr.Frame != 2 {
+			t.Errorf("%d threads, truncated: got %v, want ErrCorrupt in frame 2", threads, err)
+		}
+	}
+}
+
+func FuzzTest(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, archive []byte) {
+		d, _ := NewDecompressor(fuzzOptions)
+		if err := d.Test(bytes.NewReader(archive)); err != nil && errorKind(err) == nil {
+			t.Fatalf("error without a kind: %v", err)
+		}
+	})
+}